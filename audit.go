@@ -0,0 +1,103 @@
+package putiosync
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/putdotio/putio-sync/v2/internal/api"
+)
+
+// Job types that delete, overwrite or move files. They are written to the audit journal.
+var auditedJobTypes = map[string]struct{}{
+	"delete-local":     {},
+	"delete-remote":    {},
+	"move-local":       {},
+	"move-remote":      {},
+	"exclude-folder":   {},
+	"overwrite-local":  {},
+	"overwrite-remote": {},
+	"keep-both":        {},
+	"rename-duplicate": {},
+}
+
+// auditRecord is a line in the audit journal.
+type auditRecord struct {
+	Time    time.Time `json:"time"`
+	Host    string    `json:"host"`
+	Folder  string    `json:"folder"`
+	Cycle   uint64    `json:"cycle"`
+	Type    string    `json:"type"`
+	Path    string    `json:"path"`
+	To      string    `json:"to,omitempty"`
+	Outcome string    `json:"outcome"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// auditJournal is the file that records are appended to. It is opened when Sync is started.
+var auditJournal struct {
+	m sync.Mutex
+	f *os.File
+}
+
+// openAuditJournal opens the journal file at the path in config, or in the data dir if it is not set.
+func openAuditJournal() error {
+	p := cfg.AuditLog
+	if p == "" {
+		var err error
+		p, err = dataFile("audit.log")
+		if err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	auditJournal.m.Lock()
+	auditJournal.f = f
+	auditJournal.m.Unlock()
+	return nil
+}
+
+func closeAuditJournal() error {
+	auditJournal.m.Lock()
+	defer auditJournal.m.Unlock()
+	if auditJournal.f == nil {
+		return nil
+	}
+	err := auditJournal.f.Close()
+	auditJournal.f = nil
+	return err
+}
+
+// writeAudit appends the job to the journal as a JSON line if it deletes or moves a file.
+// Skipped jobs are not written because nothing is changed by them.
+func writeAudit(e *api.HistoryEntry) error {
+	if _, ok := auditedJobTypes[e.Type]; !ok || e.Outcome == jobSkipped {
+		return nil
+	}
+	b, err := json.Marshal(auditRecord{
+		Time:    e.FinishedAt,
+		Host:    hostname,
+		Folder:  e.Folder,
+		Cycle:   e.Cycle,
+		Type:    e.Type,
+		Path:    e.Path,
+		To:      e.To,
+		Outcome: e.Outcome,
+		Error:   e.Error,
+	})
+	if err != nil {
+		return err
+	}
+	auditJournal.m.Lock()
+	defer auditJournal.m.Unlock()
+	if auditJournal.f == nil {
+		return nil
+	}
+	// Each record is written with a single call, so lines are not mixed with other writers of the file.
+	_, err = auditJournal.f.Write(append(b, '\n'))
+	return err
+}
@@ -0,0 +1,176 @@
+package putiosync
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/log"
+	"github.com/putdotio/putio-sync/v2/internal/ratelimit"
+	"github.com/putdotio/putio-sync/v2/internal/schedule"
+)
+
+// Limiters are shared by all transfers in all sync pairs.
+var (
+	uploadLimiter   ratelimit.Limiter
+	downloadLimiter ratelimit.Limiter
+)
+
+// How often scheduled limits are checked.
+const bandwidthScheduleInterval = 30 * time.Second
+
+// bandwidthRule is a parsed BandwidthLimit.
+type bandwidthRule struct {
+	window   schedule.Window
+	upload   int64
+	download int64
+}
+
+// bandwidth contains the limits from config and the limits set at runtime.
+var bandwidth struct {
+	m        sync.Mutex
+	upload   int64
+	download int64
+	rules    []bandwidthRule
+	// Limits set through the HTTP server take precedence over config until they are cleared.
+	uploadOverride   *int64
+	downloadOverride *int64
+}
+
+// bandwidthLimits is the body of requests and responses of the limits endpoint.
+// Limits are in the format accepted by ratelimit.ParseRate.
+type bandwidthLimits struct {
+	Upload   *string `json:"upload,omitempty"`
+	Download *string `json:"download,omitempty"`
+}
+
+func parseBandwidthSchedule(c *Config) ([]bandwidthRule, error) {
+	rules := make([]bandwidthRule, 0, len(c.BandwidthSchedule))
+	for _, l := range c.BandwidthSchedule {
+		var r bandwidthRule
+		var err error
+		r.window, err = schedule.Parse(l.Window)
+		if err != nil {
+			return nil, err
+		}
+		r.upload, err = ratelimit.ParseRate(l.Upload)
+		if err != nil {
+			return nil, err
+		}
+		r.download, err = ratelimit.ParseRate(l.Download)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// initBandwidth reads limits from config and starts applying them.
+func initBandwidth(ctx context.Context) error {
+	rules, err := parseBandwidthSchedule(&cfg)
+	if err != nil {
+		return err
+	}
+	upload, err := ratelimit.ParseRate(cfg.UploadLimit)
+	if err != nil {
+		return err
+	}
+	download, err := ratelimit.ParseRate(cfg.DownloadLimit)
+	if err != nil {
+		return err
+	}
+	bandwidth.m.Lock()
+	bandwidth.rules = rules
+	bandwidth.upload = upload
+	bandwidth.download = download
+	bandwidth.m.Unlock()
+	applyBandwidthLimits()
+	go func() {
+		ticker := time.NewTicker(bandwidthScheduleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				applyBandwidthLimits()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// currentBandwidthLimits returns the limits that apply at time t.
+func currentBandwidthLimits(t time.Time) (upload, download int64) {
+	bandwidth.m.Lock()
+	defer bandwidth.m.Unlock()
+	upload, download = bandwidth.upload, bandwidth.download
+	for _, r := range bandwidth.rules {
+		if r.window.Contains(t) {
+			upload, download = r.upload, r.download
+			break
+		}
+	}
+	if bandwidth.uploadOverride != nil {
+		upload = *bandwidth.uploadOverride
+	}
+	if bandwidth.downloadOverride != nil {
+		download = *bandwidth.downloadOverride
+	}
+	return
+}
+
+func applyBandwidthLimits() {
+	upload, download := currentBandwidthLimits(time.Now())
+	if upload != uploadLimiter.Rate() {
+		log.Infof("Upload limit is %s", ratelimit.FormatRate(upload))
+		uploadLimiter.SetRate(upload)
+	}
+	if download != downloadLimiter.Rate() {
+		log.Infof("Download limit is %s", ratelimit.FormatRate(download))
+		downloadLimiter.SetRate(download)
+	}
+}
+
+// setBandwidthLimits changes the limits at runtime.
+// Nil values are not changed. Empty strings clear the limits set before, so limits from config apply again.
+func setBandwidthLimits(l bandwidthLimits) error {
+	parse := func(s *string) (*int64, error) {
+		if *s == "" {
+			return nil, nil
+		}
+		rate, err := ratelimit.ParseRate(*s)
+		return &rate, err
+	}
+	var upload, download *int64
+	var err error
+	if l.Upload != nil {
+		upload, err = parse(l.Upload)
+		if err != nil {
+			return err
+		}
+	}
+	if l.Download != nil {
+		download, err = parse(l.Download)
+		if err != nil {
+			return err
+		}
+	}
+	bandwidth.m.Lock()
+	if l.Upload != nil {
+		bandwidth.uploadOverride = upload
+	}
+	if l.Download != nil {
+		bandwidth.downloadOverride = download
+	}
+	bandwidth.m.Unlock()
+	applyBandwidthLimits()
+	return nil
+}
+
+func getBandwidthLimits() bandwidthLimits {
+	upload, download := uploadLimiter.Rate(), downloadLimiter.Rate()
+	u, d := ratelimit.FormatRate(upload), ratelimit.FormatRate(download)
+	return bandwidthLimits{Upload: &u, Download: &d}
+}
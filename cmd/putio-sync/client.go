@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const clientTimeout = 10 * time.Second
+
+const unixPrefix = "unix:"
+
+// serverURL returns the URL of the endpoint on the server of the running putio-sync process.
+func serverURL(endpoint string) (string, error) {
+	if config.Server == "" {
+		return "", errors.New("server address is not set in config")
+	}
+	if strings.HasPrefix(config.Server, unixPrefix) {
+		// Host is not used, requests are sent to the socket by the transport.
+		return "http://putio-sync" + endpoint, nil
+	}
+	addr := config.Server
+	if strings.HasPrefix(addr, ":") {
+		addr = "127.0.0.1" + addr
+	}
+	scheme := "http"
+	if config.ServerTLSCert != "" {
+		scheme = "https"
+	}
+	return scheme + "://" + addr + endpoint, nil
+}
+
+// newClient returns a client that connects to the server with the settings in config.
+// Zero timeout means no timeout, for streaming responses.
+func newClient(timeout time.Duration) (*http.Client, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if strings.HasPrefix(config.Server, unixPrefix) {
+		path := strings.TrimPrefix(config.Server, unixPrefix)
+		t.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		}
+	}
+	if config.ServerTLSCert != "" {
+		tlsConfig, err := pinnedTLSConfig(config.ServerTLSCert)
+		if err != nil {
+			return nil, err
+		}
+		t.TLSClientConfig = tlsConfig
+	}
+	return &http.Client{Transport: t, Timeout: timeout}, nil
+}
+
+// pinnedTLSConfig returns a TLS config that accepts only the server certificate in the file.
+// Host name is not verified, so self-signed certificates work when connecting to 127.0.0.1.
+func pinnedTLSConfig(certFile string) (*tls.Config, error) {
+	b, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("no certificate in file %q", certFile)
+	}
+	cert := block.Bytes
+	return &tls.Config{
+		InsecureSkipVerify: true, // nolint: gosec
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 || !bytes.Equal(rawCerts[0], cert) {
+				return errors.New("server certificate does not match ServerTLSCert in config")
+			}
+			return nil
+		},
+	}, nil
+}
+
+// apiRequest sends a request to the server with the credentials in config.
+func apiRequest(method, endpoint string, body io.Reader, timeout time.Duration) (*http.Response, error) {
+	u, err := serverURL(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newClient(timeout)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, u, body) // nolint: noctx
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	switch {
+	case config.ServerToken != "":
+		req.Header.Set("Authorization", "Bearer "+config.ServerToken)
+	case config.ServerUsername != "":
+		req.SetBasicAuth(config.ServerUsername, config.ServerPassword)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	err = checkResponse(resp)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// apiGet does a GET request to the server and decodes the JSON response into v.
+func apiGet(endpoint string, v interface{}) error {
+	resp, err := apiRequest(http.MethodGet, endpoint, nil, clientTimeout)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// apiPost does a POST request to the server with v encoded as JSON in body.
+func apiPost(endpoint string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	resp, err := apiRequest(http.MethodPost, endpoint, bytes.NewReader(b), clientTimeout)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return &responseError{status: resp.Status, body: strings.TrimSpace(string(b))}
+}
+
+// responseError is returned when the server responds with an error status.
+type responseError struct {
+	status string
+	body   string
+}
+
+func (e *responseError) Error() string {
+	return fmt.Sprintf("server returned %s: %s", e.status, e.body)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/putdotio/putio-sync/v2/internal/api"
+)
+
+// runConflicts lists the conflicts or resolves a conflict.
+//
+//	putio-sync conflicts [-json]
+//	putio-sync conflicts resolve [-folder name] <path> <keep-local|keep-remote|keep-both>
+func runConflicts(args []string) error {
+	if len(args) > 0 && args[0] == "resolve" {
+		return resolveConflict(args[1:])
+	}
+	fs := flag.NewFlagSet("conflicts", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print output in JSON format")
+	_ = fs.Parse(args)
+	if fs.NArg() != 0 {
+		return errors.New("usage: putio-sync conflicts [-json]")
+	}
+	var l []api.Conflict
+	err := apiGet("/conflicts", &l)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(l)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FOLDER\tPATH\tKIND\tLOCAL\tREMOTE\tFIRST SEEN\tRESOLUTION")
+	for _, c := range l {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", c.Folder, c.Path, c.Kind, sideString(c.Local), sideString(c.Remote), c.FirstSeen.Local().Format(time.DateTime), c.Resolution)
+	}
+	return tw.Flush()
+}
+
+// sideString describes the file on one side of a conflict.
+func sideString(s *api.ConflictSide) string {
+	switch {
+	case s == nil:
+		return "-"
+	case s.IsDir:
+		return "folder"
+	default:
+		return fmt.Sprintf("%d bytes, %s", s.Size, s.ModTime.Local().Format(time.DateTime))
+	}
+}
+
+func resolveConflict(args []string) error {
+	fs := flag.NewFlagSet("resolve", flag.ExitOnError)
+	folder := fs.String("folder", "", "name of the sync pair, can be omitted if there is only one")
+	_ = fs.Parse(args)
+	if fs.NArg() != 2 {
+		return errors.New("usage: putio-sync conflicts resolve [-folder name] <path> <keep-local|keep-remote|keep-both>")
+	}
+	return apiPost("/conflicts/resolve", map[string]string{
+		"folder": *folder,
+		"path":   fs.Arg(0),
+		"action": fs.Arg(1),
+	})
+}
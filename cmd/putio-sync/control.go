@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/putdotio/putio-sync/v2/internal/api"
+)
+
+// jobProgress returns the transfer progress of the job in a human readable format.
+func jobProgress(j *api.Job) string {
+	if j.BytesTotal == 0 {
+		return "-"
+	}
+	s := fmt.Sprintf("%d%% of %d MB", j.BytesDone*100/j.BytesTotal, j.BytesTotal/(1<<20))
+	if j.State == "running" {
+		s += fmt.Sprintf(", %d KB/s, %s left", j.Speed/(1<<10), time.Duration(j.ETA)*time.Second)
+	}
+	return s
+}
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// runStatus prints the status of the running putio-sync process.
+//
+//	putio-sync status [-json]
+func runStatus(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print output in JSON format")
+	_ = fs.Parse(args)
+	if fs.NArg() != 0 {
+		return errors.New("usage: putio-sync status [-json]")
+	}
+	return printStatus(*asJSON)
+}
+
+func printStatus(asJSON bool) error {
+	var s api.Status
+	err := apiGet("/status", &s)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		return printJSON(s)
+	}
+	fmt.Println(s.Status)
+	if s.Queued.Jobs > 0 {
+		fmt.Printf("%d jobs queued, %d MB to transfer.\n", s.Queued.Jobs, s.Queued.Bytes/(1<<20))
+	}
+	if s.Paused {
+		fmt.Println("Sync is paused, run \"putio-sync resume\" to continue.")
+	}
+	if len(s.Quarantined) > 0 {
+		fmt.Printf("\n%d files are quarantined after failing repeatedly, run \"putio-sync retry\" to sync them again:\n", len(s.Quarantined))
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "FOLDER\tPATH\tATTEMPTS\tSINCE\tLAST ERROR")
+		for _, q := range s.Quarantined {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", q.Folder, q.Path, q.Attempts, q.Since.Local().Format(time.DateTime), q.LastError)
+		}
+		return tw.Flush()
+	}
+	return nil
+}
+
+// runControl sends a command to the running putio-sync process and prints the status after it.
+//
+//	putio-sync trigger|pause|resume [-json]
+func runControl(name string, args []string) error {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print output in JSON format")
+	_ = fs.Parse(args)
+	if fs.NArg() != 0 {
+		return fmt.Errorf("usage: putio-sync %s [-json]", name)
+	}
+	err := apiPost("/"+name, struct{}{})
+	if err != nil {
+		return err
+	}
+	return printStatus(*asJSON)
+}
+
+// runJobs prints the running, queued and recently finished jobs.
+//
+//	putio-sync jobs [-json]
+func runJobs(args []string) error {
+	fs := flag.NewFlagSet("jobs", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print output in JSON format")
+	_ = fs.Parse(args)
+	if fs.NArg() != 0 {
+		return errors.New("usage: putio-sync jobs [-json]")
+	}
+	var l []api.Job
+	err := apiGet("/jobs", &l)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(l)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tFOLDER\tTYPE\tPATH\tSTATE\tPROGRESS\tATTEMPT\tERROR")
+	var queued int
+	for _, j := range l {
+		if j.State == "queued" {
+			queued++
+		}
+		p := j.Path
+		if j.To != "" {
+			p += " -> " + j.To
+		}
+		e := j.Error
+		if e == "" {
+			e = j.LastError
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n", j.ID, j.Folder, j.Type, p, j.State, jobProgress(&j), j.Attempt, e)
+	}
+	err = tw.Flush()
+	if err != nil {
+		return err
+	}
+	// Server lists only some of the queued jobs.
+	var s api.Status
+	err = apiGet("/status", &s)
+	if err != nil {
+		return err
+	}
+	if s.Queued.Jobs > queued {
+		fmt.Printf("... and %d more queued jobs\n", s.Queued.Jobs-queued)
+	}
+	return nil
+}
+
+// runLogs prints recent log messages of the running putio-sync process.
+// With -f, new messages are printed until the command is interrupted.
+//
+//	putio-sync logs [-f] [-json]
+func runLogs(args []string) error {
+	fs := flag.NewFlagSet("logs", flag.ExitOnError)
+	follow := fs.Bool("f", false, "keep printing new messages")
+	asJSON := fs.Bool("json", false, "print each message as a JSON line")
+	_ = fs.Parse(args)
+	if fs.NArg() != 0 {
+		return errors.New("usage: putio-sync logs [-f] [-json]")
+	}
+	printEntry := func(e api.LogEntry) error {
+		if *asJSON {
+			return json.NewEncoder(os.Stdout).Encode(e)
+		}
+		_, err := fmt.Printf("%s %-8s %s\n", e.Time.Local().Format(time.DateTime), e.Level, e.Message)
+		return err
+	}
+	if !*follow {
+		var l []api.LogEntry
+		err := apiGet("/logs", &l)
+		if err != nil {
+			return err
+		}
+		for _, e := range l {
+			if err = printEntry(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if _, err := serverURL("/logs"); err != nil {
+		return err
+	}
+	// Reconnect if the stream is closed, e.g. the process is restarted.
+	// Errors returned by the server and errors writing to stdout are not retried.
+	var since int64
+	for {
+		var writeErr error
+		err := followLogs(since, func(e api.LogEntry) error {
+			since = e.Seq
+			writeErr = printEntry(e)
+			return writeErr
+		})
+		if writeErr != nil {
+			return writeErr
+		}
+		var respErr *responseError
+		if errors.As(err, &respErr) {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "Disconnected from putio-sync:", err)
+		time.Sleep(time.Second)
+	}
+}
+
+func followLogs(since int64, fn func(api.LogEntry) error) error {
+	// Stream is not limited with a timeout.
+	resp, err := apiRequest(http.MethodGet, "/logs?follow=1&since="+strconv.FormatInt(since, 10), nil, 0)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var e api.LogEntry
+		err = dec.Decode(&e)
+		if err != nil {
+			return err
+		}
+		err = fn(e)
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"flag"
+)
+
+// runConfirmDeletes allows the deletions blocked for exceeding the limits to be done on next sync.
+//
+//	putio-sync confirm-deletes [-folder name]
+func runConfirmDeletes(args []string) error {
+	fs := flag.NewFlagSet("confirm-deletes", flag.ExitOnError)
+	folder := fs.String("folder", "", "name of the sync pair, can be omitted if there is only one")
+	_ = fs.Parse(args)
+	if fs.NArg() != 0 {
+		return errors.New("usage: putio-sync confirm-deletes [-folder name]")
+	}
+	return apiPost("/confirm-deletes", map[string]string{"folder": *folder})
+}
+
+// runResetRoot makes the running sync accept the current local dir as the root of the sync pair.
+// Files that are synced before are forgotten, so nothing is deleted on next sync.
+//
+//	putio-sync reset-root [-folder name]
+func runResetRoot(args []string) error {
+	fs := flag.NewFlagSet("reset-root", flag.ExitOnError)
+	folder := fs.String("folder", "", "name of the sync pair, can be omitted if there is only one")
+	_ = fs.Parse(args)
+	if fs.NArg() != 0 {
+		return errors.New("usage: putio-sync reset-root [-folder name]")
+	}
+	return apiPost("/reset-root", map[string]string{"folder": *folder})
+}
+
+// runRetry makes the running sync retry failed and quarantined files on next sync without waiting.
+// All failed files of the sync pair are retried if path is not given.
+//
+//	putio-sync retry [-folder name] [path]
+func runRetry(args []string) error {
+	fs := flag.NewFlagSet("retry", flag.ExitOnError)
+	folder := fs.String("folder", "", "name of the sync pair, can be omitted if there is only one")
+	_ = fs.Parse(args)
+	if fs.NArg() > 1 {
+		return errors.New("usage: putio-sync retry [-folder name] [path]")
+	}
+	return apiPost("/retry", map[string]string{"folder": *folder, "path": fs.Arg(0)})
+}
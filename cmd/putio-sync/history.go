@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/putdotio/putio-sync/v2/internal/api"
+)
+
+// runHistory prints the finished jobs recorded by the running putio-sync, newest first.
+//
+//	putio-sync history [-path path] [-since time|duration] [-json]
+func runHistory(args []string) error {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	p := fs.String("path", "", "print only the jobs of this file or folder")
+	since := fs.String("since", "", "print only the jobs finished after this time (RFC 3339) or duration, e.g. 24h")
+	asJSON := fs.Bool("json", false, "print output in JSON format")
+	_ = fs.Parse(args)
+	if fs.NArg() != 0 {
+		return errors.New("usage: putio-sync history [-path path] [-since time|duration] [-json]")
+	}
+	q := url.Values{}
+	if *p != "" {
+		q.Set("path", *p)
+	}
+	if *since != "" {
+		q.Set("since", *since)
+	}
+	endpoint := "/history"
+	if len(q) > 0 {
+		endpoint += "?" + q.Encode()
+	}
+	var l []api.HistoryEntry
+	err := apiGet(endpoint, &l)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(l)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FINISHED\tCYCLE\tFOLDER\tTYPE\tPATH\tBYTES\tDURATION\tOUTCOME\tERROR")
+	for _, e := range l {
+		path := e.Path
+		if e.To != "" {
+			path += " -> " + e.To
+		}
+		d := time.Duration(e.Duration * float64(time.Second)).Round(time.Millisecond)
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n",
+			e.FinishedAt.Local().Format(time.DateTime), e.Cycle, e.Folder, e.Type, path, e.Bytes, d, e.Outcome, e.Error)
+	}
+	return tw.Flush()
+}
@@ -44,6 +44,35 @@ func versionString() string {
 	return fmt.Sprintf("%s (%s) [%s]", version, commit, date)
 }
 
+func runCommand(name string, args []string) error {
+	switch name {
+	case "status":
+		return runStatus(args)
+	case "trigger", "pause", "resume":
+		return runControl(name, args)
+	case "jobs":
+		return runJobs(args)
+	case "logs":
+		return runLogs(args)
+	case "history":
+		return runHistory(args)
+	case "conflicts":
+		return runConflicts(args)
+	case "trash":
+		return runTrash(args)
+	case "confirm-deletes":
+		return runConfirmDeletes(args)
+	case "reset-root":
+		return runResetRoot(args)
+	case "retry":
+		return runRetry(args)
+	case "plan":
+		return runPlan(args)
+	default:
+		return fmt.Errorf("unknown command: %q", name)
+	}
+}
+
 func main() {
 	var err error
 	flag.Parse()
@@ -67,6 +96,20 @@ func main() {
 		return
 	}
 
+	// Subcommands manage the running putio-sync process or its data.
+	if flag.NArg() > 0 {
+		err = config.Read(configPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = runCommand(flag.Arg(0), flag.Args()[1:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
 	log.Infoln("Starting putio-sync version", versionString())
 	log.Infof("Using config file %q", configPath)
 
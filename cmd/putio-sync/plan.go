@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"text/tabwriter"
+
+	putiosync "github.com/putdotio/putio-sync/v2"
+)
+
+const planUsage = `usage: putio-sync plan [-format table|json]
+
+Prints what a sync would do without changing anything.
+The database is locked while putio-sync is running, so it must be stopped before making a plan.`
+
+// runPlan prints what a sync would do without changing anything.
+// The running putio-sync must be stopped because the database is locked while it is running.
+func runPlan(args []string) error {
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	format := fs.String("format", "table", "output format: table or json")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), planUsage)
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 0 || (*format != "table" && *format != "json") {
+		return errors.New(planUsage)
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	plan, err := putiosync.MakePlan(ctx, config)
+	if err != nil {
+		return err
+	}
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(plan)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FOLDER\tTYPE\tPATH\tSIZE\tREASON")
+	for _, f := range plan.Folders {
+		for _, e := range f.Entries {
+			p := e.Path
+			if e.To != "" {
+				p = e.From + " -> " + e.To
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", f.Folder, e.Type, p, e.Size, e.Reason)
+		}
+	}
+	err = tw.Flush()
+	if err != nil {
+		return err
+	}
+	fmt.Println()
+	for _, f := range plan.Folders {
+		if f.Error != "" {
+			fmt.Printf("Folder %q cannot be synced: %s\n", f.Folder, f.Error)
+		}
+		if f.Blocked != "" {
+			fmt.Printf("Deletions would be blocked: %s\n", f.Blocked)
+		}
+	}
+	types := make([]string, 0, len(plan.Summary.Counts))
+	for t := range plan.Summary.Counts {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		fmt.Printf("%s: %d\n", t, plan.Summary.Counts[t])
+	}
+	fmt.Printf("Total bytes: %d\n", plan.Summary.TotalBytes)
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/putdotio/putio-sync/v2/internal/trash"
+)
+
+const trashUsage = `usage:
+  putio-sync trash list [-folder name]
+  putio-sync trash restore [-folder name] <path> [target path]
+  putio-sync trash empty [-folder name]`
+
+// runTrash manages the trash folders of sync pairs.
+// Unlike other commands, it works on local dirs directly and does not need the server.
+// Trash index is locked while it is changed, so it can be run while the program is syncing.
+func runTrash(args []string) error {
+	if len(args) == 0 {
+		return errors.New(trashUsage)
+	}
+	fs := flag.NewFlagSet("trash", flag.ExitOnError)
+	folder := fs.String("folder", "", "name of the sync pair, all pairs if omitted")
+	_ = fs.Parse(args[1:])
+	dirs, err := config.LocalDirs()
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(dirs))
+	for name := range dirs {
+		if *folder == "" || *folder == name {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("folder not found: %q", *folder)
+	}
+	sort.Strings(names)
+	switch args[0] {
+	case "list":
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "FOLDER\tPATH\tORIGINAL PATH\tDELETED AT\tSIZE")
+		for _, name := range names {
+			items, err := trash.New(dirs[name]).List()
+			if err != nil {
+				return err
+			}
+			for _, item := range items {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\n", name, item.Path, item.OrigPath, item.DeletedAt.Local().Format(time.DateTime), item.Size)
+			}
+		}
+		return tw.Flush()
+	case "restore":
+		if fs.NArg() < 1 || fs.NArg() > 2 {
+			return errors.New(trashUsage)
+		}
+		if len(names) > 1 {
+			return errors.New("folder must be given when there are multiple sync pairs")
+		}
+		return trash.New(dirs[names[0]]).Restore(fs.Arg(0), fs.Arg(1))
+	case "empty":
+		for _, name := range names {
+			err = trash.New(dirs[name]).Empty()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return errors.New(trashUsage)
+	}
+}
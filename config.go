@@ -1,13 +1,20 @@
 package putiosync
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/knadh/koanf"
 	"github.com/knadh/koanf/parsers/toml"
 	"github.com/knadh/koanf/providers/env"
 	"github.com/knadh/koanf/providers/file"
+	"github.com/putdotio/putio-sync/v2/internal/ratelimit"
+	"github.com/putdotio/putio-sync/v2/internal/size"
+	"github.com/syncthing/syncthing/lib/fs"
 )
 
 type ConfigError struct {
@@ -31,7 +38,68 @@ type Config struct {
 	// In that case, Username is not required.
 	Password string
 	// Sync files to/from this dir in computer.
+	// Used only when Folders is empty.
 	LocalDir string
+	// Slash separated path of the remote folder in put.io account.
+	// Used only when Folders is empty.
+	RemotePath string
+	// ID of the remote folder in put.io account. Can be used instead of RemotePath.
+	// Used only when Folders is empty.
+	RemoteFolderID int64
+	// Sync mode. Used only when Folders is empty.
+	Mode string
+	// Remote subfolders to be synced. Used only when Folders is empty.
+	Include []string
+	// Action for local copies of excluded folders. Used only when Folders is empty.
+	ExcludeAction string
+	// Conflict resolution policy. Used only when Folders is empty.
+	ConflictPolicy string
+	// List of sync pairs. Each pair syncs a local dir with a remote folder.
+	// If empty, a single pair is created from LocalDir.
+	Folders []FolderConfig
+	// Gitignore style patterns for files that are not synced in all folders.
+	// Patterns can also be put in ".putioignore" files at any level of the local dirs.
+	Ignore []string
+	// Maximum number of files uploaded at the same time. Defaults to 2.
+	UploadConcurrency int
+	// Maximum number of files downloaded at the same time. Defaults to 2.
+	DownloadConcurrency int
+	// Maximum upload speed shared by all uploads, e.g. "2MB/s". Unlimited by default.
+	UploadLimit string
+	// Maximum download speed shared by all downloads, e.g. "10MB/s". Unlimited by default.
+	DownloadLimit string
+	// Limits that apply at certain times instead of UploadLimit and DownloadLimit.
+	// First entry that contains the current time is used.
+	BandwidthSchedule []BandwidthLimit
+	// Time windows when syncing is allowed, such as "00:00-07:00" or "Sat,Sun".
+	// Changes detected outside of windows are synced when a window opens.
+	// Running transfers are paused when a window closes and resumed when the next one opens.
+	// Syncing is allowed at any time if empty.
+	SyncWindows []string
+	// Local files that are deleted because they are deleted on remote side are moved to ".putio-sync-trash" folder.
+	// Items older than this are deleted permanently. Defaults to 720h (30 days).
+	TrashMaxAge time.Duration
+	// Oldest items are deleted permanently when total size of trash exceeds this, e.g. "10GB".
+	// Unlimited by default.
+	TrashMaxSize string
+	// Deletions are not done when more than this many files are going to be deleted in a sync,
+	// until they are confirmed with "putio-sync confirm-deletes". Defaults to 50. Negative value disables the limit.
+	MaxDeletes int
+	// Same as MaxDeletes but as a percentage of synced files in the folder. Defaults to 20.
+	MaxDeletePercent int
+	// Files that fail to sync this many times in a row are quarantined and not retried until they change.
+	// Failed files are retried with increasing delays until then. Defaults to 5.
+	MaxAttempts int
+	// Remote folders are walked completely at least this often.
+	// Between full walks, only the remote folders that are changed are listed, if changes are received as events.
+	// Defaults to 6h.
+	FullWalkInterval time.Duration
+	// Number of local files hashed in parallel for detecting changes that keep the file size.
+	// Defaults to 2.
+	HashWorkers int
+	// Deletions and moves of files are appended to this file as JSON lines.
+	// Defaults to "audit.log" in the same dir with the database.
+	AuditLog string
 	// Do not make changes on filesystems. Only calculate what needs to be done.
 	DryRun bool
 	// Stop after first sync operation.
@@ -39,12 +107,62 @@ type Config struct {
 	// If there is authentication error, Sync also returns since there is no point in retrying in this case.
 	Once bool
 	// Listen address for HTTP server.
-	// The server has an endpoint for getting the status of the sync operation.
+	// The server has endpoints for getting the status of the sync operation and controlling it.
+	// Can be a TCP address such as "127.0.0.1:3000", "[::1]:3000" or ":3000" (all IPv4 and IPv6 addresses),
+	// or a Unix socket path prefixed with "unix:", such as "unix:/run/putio-sync.sock".
 	Server string
+	// Requests to the server must have this token in "Authorization: Bearer <token>" header.
+	ServerToken string
+	// Requests to the server must have these credentials in basic auth header.
+	// Can be used together with ServerToken, requests having either of them are accepted.
+	ServerUsername string
+	ServerPassword string
+	// Paths of the certificate and key files for serving HTTPS.
+	ServerTLSCert string
+	ServerTLSKey  string
+	// Permissions of the Unix socket file in octal. Defaults to "0600".
+	ServerSocketMode string
 	// Set log level to debug.
 	Debug bool
 }
 
+// BandwidthLimit sets transfer speed limits for a time window.
+type BandwidthLimit struct {
+	// Time window such as "09:00-18:00 Mon-Fri", "22:00-06:00" or "Sat,Sun".
+	Window string
+	// Maximum upload speed, e.g. "2MB/s". Empty means unlimited.
+	Upload string
+	// Maximum download speed, e.g. "10MB/s". Empty means unlimited.
+	Download string
+}
+
+// FolderConfig is a sync pair that maps a local dir to a remote folder.
+type FolderConfig struct {
+	// Name of the sync pair. Sync state is stored separately for each name.
+	// Defaults to LocalDir.
+	Name string
+	// Sync files to/from this dir in computer.
+	LocalDir string
+	// Slash separated path of the remote folder in put.io account.
+	// Missing folders in the path are created.
+	// Defaults to "putio-sync".
+	RemotePath string
+	// ID of the remote folder in put.io account. Can be used instead of RemotePath.
+	RemoteFolderID int64
+	// Sync mode. One of "two-way", "download-only", "upload-only", "mirror-remote" or "mirror-local".
+	// Defaults to "two-way".
+	Mode string
+	// Slash separated paths of remote subfolders to be synced, relative to the remote folder.
+	// If empty, all files are synced.
+	Include []string
+	// Action for local copies of folders that are removed from Include list.
+	// One of "keep" or "remove". Defaults to "keep".
+	ExcludeAction string
+	// What to do when a file is changed on both sides.
+	// One of "keep-both", "newest-wins", "local-wins", "remote-wins" or "skip". Defaults to "skip".
+	ConflictPolicy string
+}
+
 func (c *Config) validate() error {
 	if c.Username == "" && !strings.HasPrefix(c.Password, "token/") {
 		return newConfigError("empty username")
@@ -52,9 +170,112 @@ func (c *Config) validate() error {
 	if c.Password == "" {
 		return newConfigError("empty password")
 	}
+	_, err := ratelimit.ParseRate(c.UploadLimit)
+	if err != nil {
+		return newConfigError("upload limit: " + err.Error())
+	}
+	_, err = ratelimit.ParseRate(c.DownloadLimit)
+	if err != nil {
+		return newConfigError("download limit: " + err.Error())
+	}
+	_, err = parseBandwidthSchedule(c)
+	if err != nil {
+		return newConfigError(err.Error())
+	}
+	_, err = size.Parse(c.TrashMaxSize)
+	if err != nil {
+		return newConfigError(err.Error())
+	}
+	_, err = parseSyncWindows(c)
+	if err != nil {
+		return newConfigError(err.Error())
+	}
+	if (c.ServerTLSCert == "") != (c.ServerTLSKey == "") {
+		return newConfigError("both of server TLS cert and key must be set")
+	}
+	if c.ServerTLSCert != "" && strings.HasPrefix(c.Server, unixPrefix) {
+		return newConfigError("TLS cannot be used with Unix socket")
+	}
+	if c.ServerUsername != "" && c.ServerPassword == "" {
+		return newConfigError("empty server password")
+	}
+	_, err = parseSocketMode(c.ServerSocketMode)
+	if err != nil {
+		return newConfigError(err.Error())
+	}
+	names := make(map[string]struct{})
+	var dirs []string
+	for _, f := range c.folders() {
+		if f.LocalDir == "" {
+			return newConfigError("empty local dir in folder: " + f.Name)
+		}
+		if f.RemotePath != "" && f.RemoteFolderID != 0 {
+			return newConfigError("only one of remote path and remote folder id can be set in folder: " + f.Name)
+		}
+		if !syncMode(f.Mode).valid() {
+			return newConfigError("invalid mode in folder " + f.Name + ": " + f.Mode)
+		}
+		if !validExcludeAction(f.ExcludeAction) {
+			return newConfigError("invalid exclude action in folder " + f.Name + ": " + f.ExcludeAction)
+		}
+		if !conflictPolicy(f.ConflictPolicy).valid() {
+			return newConfigError("invalid conflict policy in folder " + f.Name + ": " + f.ConflictPolicy)
+		}
+		if _, ok := names[f.Name]; ok {
+			return newConfigError("duplicate folder name: " + f.Name)
+		}
+		names[f.Name] = struct{}{}
+		dir, err := fs.ExpandTilde(f.LocalDir)
+		if err != nil {
+			return newConfigError(err.Error())
+		}
+		dir = filepath.Clean(dir)
+		for _, other := range dirs {
+			if dir == other || strings.HasPrefix(dir, other+string(filepath.Separator)) || strings.HasPrefix(other, dir+string(filepath.Separator)) {
+				return newConfigError("local dirs must not overlap: " + f.LocalDir)
+			}
+		}
+		dirs = append(dirs, dir)
+	}
 	return nil
 }
 
+// folders returns the list of sync pairs with default values filled in.
+func (c *Config) folders() []FolderConfig {
+	folders := c.Folders
+	if len(folders) == 0 {
+		folders = []FolderConfig{{
+			LocalDir:       c.LocalDir,
+			RemotePath:     c.RemotePath,
+			RemoteFolderID: c.RemoteFolderID,
+			Mode:           c.Mode,
+			Include:        c.Include,
+			ExcludeAction:  c.ExcludeAction,
+			ConflictPolicy: c.ConflictPolicy,
+		}}
+	}
+	l := make([]FolderConfig, 0, len(folders))
+	for _, f := range folders {
+		if f.Name == "" {
+			f.Name = f.LocalDir
+		}
+		if f.RemotePath == "" && f.RemoteFolderID == 0 {
+			f.RemotePath = remoteFolderName
+		}
+		if f.Mode == "" {
+			f.Mode = string(modeTwoWay)
+		}
+		if f.ExcludeAction == "" {
+			f.ExcludeAction = excludeActionKeep
+		}
+		if f.ConflictPolicy == "" {
+			f.ConflictPolicy = string(conflictSkip)
+		}
+		l = append(l, f)
+	}
+	return l
+}
+
 func (c *Config) Read(configPath string) error {
 	k := koanf.New(".")
 	err := k.Load(file.Provider(configPath), toml.Parser())
@@ -76,7 +297,39 @@ func (c *Config) Read(configPath string) error {
 }
 
 func (c *Config) setDefaults() {
-	if c.LocalDir == "" {
+	if c.LocalDir == "" && len(c.Folders) == 0 {
 		c.LocalDir = "~/putio-sync"
 	}
+	if c.TrashMaxAge == 0 {
+		c.TrashMaxAge = defaultTrashMaxAge
+	}
+}
+
+// LocalDirs returns the local dirs of sync pairs by their names.
+func (c *Config) LocalDirs() (map[string]string, error) {
+	m := make(map[string]string)
+	for _, f := range c.folders() {
+		dir, err := fs.ExpandTilde(f.LocalDir)
+		if err != nil {
+			return nil, err
+		}
+		m[f.Name] = dir
+	}
+	return m, nil
+}
+
+// unixPrefix is the prefix of Server address for listening on a Unix socket.
+const unixPrefix = "unix:"
+
+const defaultSocketMode = 0600
+
+func parseSocketMode(s string) (os.FileMode, error) {
+	if s == "" {
+		return defaultSocketMode, nil
+	}
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid server socket mode: %q", s)
+	}
+	return os.FileMode(mode), nil
 }
@@ -0,0 +1,157 @@
+package putiosync
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// conflictPolicy determines what to do when both sides of a file have changed
+// or one side is a folder while the other side is a file.
+type conflictPolicy string
+
+const (
+	// Local file is renamed and both files are synced.
+	conflictKeepBoth conflictPolicy = "keep-both"
+	// File with the latest modification time overwrites the other.
+	conflictNewestWins conflictPolicy = "newest-wins"
+	// Local file overwrites the remote file.
+	conflictLocalWins conflictPolicy = "local-wins"
+	// Remote file overwrites the local file.
+	conflictRemoteWins conflictPolicy = "remote-wins"
+	// Conflicting files are not synced.
+	conflictSkip conflictPolicy = "skip"
+)
+
+func (p conflictPolicy) valid() bool {
+	switch p {
+	case conflictKeepBoth, conflictNewestWins, conflictLocalWins, conflictRemoteWins, conflictSkip:
+		return true
+	default:
+		return false
+	}
+}
+
+// markConflict marks the file as conflicting and returns the jobs for resolving the conflict.
+func markConflict(sf *syncFile, kind conflictKind, opts reconOptions) []iJob {
+	sf.conflict = kind
+	var jobs []iJob
+	if kind == conflictKindNameCollision {
+		jobs = resolveNameCollision(sf, opts)
+	} else {
+		jobs = resolveConflict(sf, opts)
+	}
+	sf.conflictResolved = jobs != nil
+	return jobs
+}
+
+// policyFor returns the conflict policy for the file.
+// Action set by the user for the file takes precedence over the policy in config.
+func policyFor(sf *syncFile, opts reconOptions) conflictPolicy {
+	switch opts.resolutions[sf.relpath] {
+	case resolveKeepLocal:
+		return conflictLocalWins
+	case resolveKeepRemote:
+		return conflictRemoteWins
+	case resolveKeepBoth:
+		return conflictKeepBoth
+	default:
+		return opts.conflictPolicy
+	}
+}
+
+// resolveConflict returns the jobs for resolving the conflict according to the conflict policy.
+// Returns nil if the conflict cannot be resolved in current sync mode or the policy is to skip.
+func resolveConflict(sf *syncFile, opts reconOptions) []iJob {
+	policy := policyFor(sf, opts)
+	if policy == conflictNewestWins {
+		if sf.local.Info().ModTime().After(remoteModTime(sf.remote)) {
+			policy = conflictLocalWins
+		} else {
+			policy = conflictRemoteWins
+		}
+	}
+	switch policy {
+	case conflictLocalWins:
+		if !opts.mode.uploads() {
+			return nil
+		}
+		return []iJob{&overwriteRemoteJob{
+			localFile:  sf.local,
+			remoteFile: sf.remote,
+			state:      sf.state,
+		}}
+	case conflictRemoteWins:
+		if !opts.mode.downloads() {
+			return nil
+		}
+		return []iJob{&overwriteLocalJob{
+			localFile:  sf.local,
+			remoteFile: sf.remote,
+			state:      sf.state,
+		}}
+	case conflictKeepBoth:
+		job := &keepBothJob{
+			localFile:  sf.local,
+			remoteFile: sf.remote,
+			state:      sf.state,
+			toRelpath:  conflictName(sf.relpath, opts.hostname, opts.now),
+		}
+		switch {
+		case opts.mode.downloads():
+			// Rename the local file, so the remote file can be copied to local side.
+			return []iJob{job}
+		case opts.mode.uploads():
+			// Local files cannot be changed, rename the remote file instead.
+			job.renameRemote = true
+			return []iJob{job}
+		}
+	}
+	return nil
+}
+
+// resolveNameCollision returns the jobs for renaming the other remote files with the same name.
+// Only the keep-both policy resolves name collisions because remote files are never deleted for them.
+func resolveNameCollision(sf *syncFile, opts reconOptions) []iJob {
+	if policyFor(sf, opts) != conflictKeepBoth || !opts.mode.uploads() {
+		return nil
+	}
+	jobs := make([]iJob, 0, len(sf.duplicates))
+	for _, rf := range sf.duplicates {
+		jobs = append(jobs, &renameDuplicateJob{
+			remoteFile: rf,
+			toRelpath:  conflictName(sf.relpath, fmt.Sprintf("put.io %d", rf.PutioFile().ID), remoteModTime(rf)),
+		})
+	}
+	return jobs
+}
+
+// isDirConflict reports whether one side of the file is a folder and the other side is a file.
+func isDirConflict(sf *syncFile) bool {
+	return sf.local != nil && sf.remote != nil && sf.local.Info().IsDir() != sf.remote.Info().IsDir()
+}
+
+func remoteModTime(rf iRemoteFile) time.Time {
+	pf := rf.PutioFile()
+	if pf.UpdatedAt != nil {
+		return pf.UpdatedAt.Time
+	}
+	if pf.CreatedAt != nil {
+		return pf.CreatedAt.Time
+	}
+	return time.Time{}
+}
+
+// conflictName returns the path for the renamed copy of a conflicting file.
+// Format is "name (conflict from <host> <date>).ext".
+func conflictName(relpath, hostname string, t time.Time) string {
+	dir, name := path.Split(relpath)
+	ext := path.Ext(name)
+	if ext == name {
+		// Hidden files like ".bashrc" do not have an extension.
+		ext = ""
+	}
+	base := strings.TrimSuffix(name, ext)
+	return dir + fmt.Sprintf("%s (conflict from %s %s)%s", base, hostname, t.Format("2006-01-02 150405"), ext)
+}
@@ -0,0 +1,229 @@
+package putiosync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/putdotio/putio-sync/v2/internal/api"
+	"go.etcd.io/bbolt"
+)
+
+// bucketConflicts contains unresolved conflicts of a sync pair.
+var bucketConflicts = []byte("conflicts")
+
+var errConflictNotFound = errors.New("conflict not found")
+
+type conflictKind string
+
+const (
+	// One side is a folder while the other side is a file.
+	conflictKindDirFile conflictKind = "dir-file"
+	// File has changed on both sides since the last sync.
+	conflictKindBothModified conflictKind = "both-modified"
+	// File is seen on both sides for the first time with different sizes.
+	conflictKindSizeMismatch conflictKind = "size-mismatch"
+	// File is seen on both sides for the first time with same size but different contents.
+	conflictKindContentMismatch conflictKind = "content-mismatch"
+	// There are multiple remote files with the same name.
+	conflictKindNameCollision conflictKind = "name-collision"
+)
+
+// Actions for resolving a conflict from the API.
+const (
+	resolveKeepLocal  = "keep-local"
+	resolveKeepRemote = "keep-remote"
+	resolveKeepBoth   = "keep-both"
+)
+
+// conflictType is the record of a conflict that is saved in the database.
+type conflictType struct {
+	Kind      conflictKind      `json:"kind"`
+	Local     *api.ConflictSide `json:"local,omitempty"`
+	Remote    *api.ConflictSide `json:"remote,omitempty"`
+	FirstSeen time.Time         `json:"firstSeen"`
+	// Action set by the user for resolving the conflict on next sync.
+	Resolution string `json:"resolution,omitempty"`
+}
+
+func newConflict(sf *syncFile, now time.Time) conflictType {
+	c := conflictType{
+		Kind:      sf.conflict,
+		FirstSeen: now,
+	}
+	if sf.local != nil {
+		c.Local = &api.ConflictSide{
+			IsDir:   sf.local.Info().IsDir(),
+			Size:    sf.local.Info().Size(),
+			CRC32:   sf.localCRC32,
+			ModTime: sf.local.Info().ModTime(),
+		}
+	}
+	if sf.remote != nil {
+		c.Remote = &api.ConflictSide{
+			IsDir:   sf.remote.Info().IsDir(),
+			Size:    sf.remote.PutioFile().Size,
+			CRC32:   sf.remote.PutioFile().CRC32,
+			ModTime: remoteModTime(sf.remote),
+		}
+	}
+	return c
+}
+
+// saveConflicts updates the conflicts of the current sync pair after reconciliation.
+// Conflicts resolved by the user are kept until the files are not conflicting anymore,
+// so the resolution is retried if the jobs fail.
+func saveConflicts(syncFiles map[string]*syncFile, now time.Time) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		b := pairBucket(tx, bucketConflicts)
+		existing := make(map[string]conflictType)
+		err := b.ForEach(func(key, val []byte) error {
+			var c conflictType
+			err := json.Unmarshal(val, &c)
+			if err != nil {
+				return err
+			}
+			existing[string(key)] = c
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for relpath, sf := range syncFiles {
+			if sf.conflict == "" {
+				continue
+			}
+			old, ok := existing[relpath]
+			if sf.conflictResolved && (!ok || old.Resolution == "") {
+				// Resolved by conflict policy.
+				continue
+			}
+			delete(existing, relpath)
+			c := newConflict(sf, now)
+			if ok && old.Kind == c.Kind {
+				c.FirstSeen = old.FirstSeen
+				c.Resolution = old.Resolution
+			}
+			val, err := json.Marshal(c)
+			if err != nil {
+				return err
+			}
+			err = b.Put([]byte(relpath), val)
+			if err != nil {
+				return err
+			}
+		}
+		// Remaining records are not conflicting anymore.
+		for relpath := range existing {
+			err = b.Delete([]byte(relpath))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// readConflictResolutions returns the actions set by the user for the conflicts of the current sync pair.
+func readConflictResolutions() (map[string]string, error) {
+	m := make(map[string]string)
+	err := db.View(func(tx *bbolt.Tx) error {
+		return pairBucket(tx, bucketConflicts).ForEach(func(key, val []byte) error {
+			var c conflictType
+			err := json.Unmarshal(val, &c)
+			if err != nil {
+				return err
+			}
+			if c.Resolution != "" {
+				m[string(key)] = c.Resolution
+			}
+			return nil
+		})
+	})
+	return m, err
+}
+
+// listConflicts returns the conflicts of all sync pairs.
+func listConflicts() ([]api.Conflict, error) {
+	l := make([]api.Conflict, 0)
+	err := db.View(func(tx *bbolt.Tx) error {
+		pairs := tx.Bucket(bucketPairs)
+		if pairs == nil {
+			return nil
+		}
+		for _, f := range cfg.Folders {
+			pair := pairs.Bucket([]byte(f.Name))
+			if pair == nil {
+				continue
+			}
+			b := pair.Bucket(bucketConflicts)
+			if b == nil {
+				continue
+			}
+			err := b.ForEach(func(key, val []byte) error {
+				var c conflictType
+				err := json.Unmarshal(val, &c)
+				if err != nil {
+					return err
+				}
+				l = append(l, api.Conflict{
+					Folder:     f.Name,
+					Path:       string(key),
+					Kind:       string(c.Kind),
+					Local:      c.Local,
+					Remote:     c.Remote,
+					FirstSeen:  c.FirstSeen,
+					Resolution: c.Resolution,
+				})
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	sort.SliceStable(l, func(i, j int) bool { return l[i].FirstSeen.Before(l[j].FirstSeen) })
+	return l, err
+}
+
+// setConflictResolution sets the action for resolving the conflict at relpath in the sync pair.
+// Jobs for the action are run on next sync.
+func setConflictResolution(f FolderConfig, relpath, action string) error {
+	switch action {
+	case resolveKeepLocal, resolveKeepRemote, resolveKeepBoth:
+	default:
+		return fmt.Errorf("invalid action: %q", action)
+	}
+	return db.Update(func(tx *bbolt.Tx) error {
+		var b *bbolt.Bucket
+		if pairs := tx.Bucket(bucketPairs); pairs != nil {
+			if pair := pairs.Bucket([]byte(f.Name)); pair != nil {
+				b = pair.Bucket(bucketConflicts)
+			}
+		}
+		if b == nil {
+			return errConflictNotFound
+		}
+		val := b.Get([]byte(relpath))
+		if val == nil {
+			return errConflictNotFound
+		}
+		var c conflictType
+		err := json.Unmarshal(val, &c)
+		if err != nil {
+			return err
+		}
+		if c.Kind == conflictKindNameCollision && action != resolveKeepBoth {
+			return fmt.Errorf("name collision can only be resolved with %q", resolveKeepBoth)
+		}
+		c.Resolution = action
+		val, err = json.Marshal(c)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(relpath), val)
+	})
+}
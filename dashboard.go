@@ -0,0 +1,41 @@
+package putiosync
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// webFS contains the files of the dashboard served at the root of the server.
+//
+//go:embed web
+var webFS embed.FS
+
+// dashboardHandler serves the dashboard page at "/" and its files under "/assets/".
+// Data is loaded from the JSON endpoints, so these files do not require authentication.
+func dashboardHandler() http.Handler {
+	sub, err := fs.Sub(webFS, "web")
+	if err != nil {
+		panic(err)
+	}
+	assets := http.StripPrefix("/assets/", http.FileServer(http.FS(sub)))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			assets.ServeHTTP(w, r)
+			return
+		}
+		b, err := fs.ReadFile(sub, "index.html")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(b)
+	})
+}
+
+// isDashboardPath reports whether the request is for a file of the dashboard.
+func isDashboardPath(p string) bool {
+	return p == "/" || strings.HasPrefix(p, "/assets/")
+}
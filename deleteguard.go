@@ -0,0 +1,162 @@
+package putiosync
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const (
+	defaultMaxDeletes       = 50
+	defaultMaxDeletePercent = 20
+	// Percent limit is not checked for small number of deletions, so deleting a few files in a small folder is not blocked.
+	minDeletesForPercent = 5
+)
+
+// deletesBlockedError is returned from syncRoots when too many files are going to be deleted.
+type deletesBlockedError struct {
+	folder  string
+	deletes int
+	tracked int
+}
+
+func (e *deletesBlockedError) Error() string {
+	return fmt.Sprintf("blocked %d deletions out of %d files in folder %q, waiting for confirmation", e.deletes, e.tracked, e.folder)
+}
+
+// onlyDeletesBlocked reports whether every error joined in err is a deletesBlockedError.
+// Other errors must be reported as usual even if deletions are blocked in another sync pair.
+func onlyDeletesBlocked(err error) bool {
+	switch e := err.(type) {
+	case nil:
+		return false
+	case *deletesBlockedError:
+		return true
+	case interface{ Unwrap() []error }:
+		for _, inner := range e.Unwrap() {
+			if !onlyDeletesBlocked(inner) {
+				return false
+			}
+		}
+		return true
+	default:
+		return onlyDeletesBlocked(errors.Unwrap(err))
+	}
+}
+
+var errNoBlockedDeletes = errors.New("no deletions are blocked")
+
+// deleteGuard contains the paths of deletions blocked in the last sync of each sync pair and the ones confirmed by the user.
+// Confirmation is valid for the next sync of the pair only, and only if it deletes no other paths.
+var deleteGuard = struct {
+	m         sync.Mutex
+	blocked   map[string]map[string]struct{}
+	confirmed map[string]map[string]struct{}
+}{
+	blocked:   make(map[string]map[string]struct{}),
+	confirmed: make(map[string]map[string]struct{}),
+}
+
+// confirmDeletes allows the deletions blocked in the last sync of the pair to be done on next sync.
+func confirmDeletes(name string) error {
+	deleteGuard.m.Lock()
+	defer deleteGuard.m.Unlock()
+	paths, ok := deleteGuard.blocked[name]
+	if !ok {
+		return errNoBlockedDeletes
+	}
+	deleteGuard.confirmed[name] = paths
+	delete(deleteGuard.blocked, name)
+	return nil
+}
+
+// takeDeleteConfirmation returns true if all paths are confirmed to be deleted in the sync pair and removes the confirmation.
+func takeDeleteConfirmation(name string, paths []string) bool {
+	deleteGuard.m.Lock()
+	defer deleteGuard.m.Unlock()
+	confirmed, ok := deleteGuard.confirmed[name]
+	if !ok {
+		return false
+	}
+	for _, p := range paths {
+		if _, ok := confirmed[p]; !ok {
+			return false
+		}
+	}
+	delete(deleteGuard.confirmed, name)
+	return true
+}
+
+// blockDeletes saves the paths of blocked deletions, so they can be confirmed.
+// Earlier confirmation is discarded because it is for another set of deletions.
+func blockDeletes(name string, paths []string) {
+	m := make(map[string]struct{}, len(paths))
+	for _, p := range paths {
+		m[p] = struct{}{}
+	}
+	deleteGuard.m.Lock()
+	deleteGuard.blocked[name] = m
+	delete(deleteGuard.confirmed, name)
+	deleteGuard.m.Unlock()
+}
+
+// deletedPaths returns the paths of files that the job deletes or overwrites on local side.
+// Remote overwrites are counted only when a whole folder is replaced with a file or vice versa.
+func deletedPaths(job iJob) []string {
+	switch j := job.(type) {
+	case *deleteLocalFileJob, *deleteRemoteFileJob, *overwriteLocalJob:
+		return j.Paths()
+	case *overwriteRemoteJob:
+		if j.localFile.Info().IsDir() != j.remoteFile.Info().IsDir() {
+			return j.Paths()
+		}
+		return nil
+	case *excludeFolderJob:
+		return j.removedPaths()
+	default:
+		return nil
+	}
+}
+
+// tooManyDeletes reports whether the number of deletions exceeds the limits in config.
+// Tracked is the number of files that have a sync state.
+func tooManyDeletes(deletes, tracked int) bool {
+	maxDeletes := cfg.MaxDeletes
+	if maxDeletes == 0 {
+		maxDeletes = defaultMaxDeletes
+	}
+	maxPercent := cfg.MaxDeletePercent
+	if maxPercent == 0 {
+		maxPercent = defaultMaxDeletePercent
+	}
+	if maxDeletes > 0 && deletes > maxDeletes {
+		return true
+	}
+	if maxPercent > 0 && deletes >= minDeletesForPercent && deletes*100 > tracked*maxPercent {
+		return true
+	}
+	return false
+}
+
+// guardDeletes removes delete jobs from the list if there are too many of them and they are not confirmed.
+// Returns the jobs to run and the error to be returned after running them.
+func guardDeletes(jobs []iJob, tracked int) ([]iJob, error) {
+	var paths []string
+	for _, job := range jobs {
+		paths = append(paths, deletedPaths(job)...)
+	}
+	if !tooManyDeletes(len(paths), tracked) {
+		return jobs, nil
+	}
+	if takeDeleteConfirmation(folder.Name, paths) {
+		return jobs, nil
+	}
+	blockDeletes(folder.Name, paths)
+	safe := make([]iJob, 0, len(jobs))
+	for _, job := range jobs {
+		if len(deletedPaths(job)) == 0 {
+			safe = append(safe, job)
+		}
+	}
+	return safe, &deletesBlockedError{folder: folder.Name, deletes: len(paths), tracked: tracked}
+}
@@ -0,0 +1,103 @@
+package putiosync
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestGuardDeletes(t *testing.T) {
+	folder = FolderConfig{Name: "test"}
+	defer func() { folder = FolderConfig{} }()
+	jobs := func(deletes int) []iJob {
+		l := []iJob{&createLocalFolderJob{relpath: "new"}}
+		for i := 0; i < deletes; i++ {
+			l = append(l, &deleteRemoteFileJob{state: stateType{relpath: fmt.Sprintf("file%d", i)}})
+		}
+		return l
+	}
+
+	l, err := guardDeletes(jobs(4), 10)
+	if err != nil || len(l) != 5 {
+		t.Errorf("few deletions must not be blocked: %v", err)
+	}
+	l, err = guardDeletes(jobs(10), 1000)
+	if err != nil || len(l) != 11 {
+		t.Errorf("deletions below limits must not be blocked: %v", err)
+	}
+	l, err = guardDeletes(jobs(30), 100)
+	var blockedErr *deletesBlockedError
+	if !errors.As(err, &blockedErr) || len(l) != 1 {
+		t.Errorf("deletions above percent limit must be blocked: %v", err)
+	}
+	l, err = guardDeletes(jobs(51), 1000)
+	if !errors.As(err, &blockedErr) || len(l) != 1 {
+		t.Errorf("deletions above count limit must be blocked: %v", err)
+	}
+	overwrites := jobs(50)
+	overwrites = append(overwrites, &overwriteLocalJob{remoteFile: fakeRemoteFile("changed")})
+	_, err = guardDeletes(overwrites, 1000)
+	if !errors.As(err, &blockedErr) || blockedErr.deletes != 51 {
+		t.Errorf("local overwrites must be counted as deletions: %v", err)
+	}
+	remoteDir := fakeRemoteFile("dir")
+	remoteDir.putioFile.ContentType = "application/x-directory"
+	overwrites = jobs(50)
+	overwrites = append(overwrites,
+		&overwriteRemoteJob{localFile: fakeLocalFile(t, "changed"), remoteFile: fakeRemoteFile("changed")},
+		&overwriteRemoteJob{localFile: fakeLocalFile(t, "dir"), remoteFile: remoteDir},
+	)
+	_, err = guardDeletes(overwrites, 1000)
+	if !errors.As(err, &blockedErr) || blockedErr.deletes != 51 {
+		t.Errorf("remote folders replaced with files must be counted as deletions: %v", err)
+	}
+
+	if err = confirmDeletes("other"); !errors.Is(err, errNoBlockedDeletes) {
+		t.Errorf("confirmation without blocked deletions must fail: %v", err)
+	}
+	_, _ = guardDeletes(jobs(51), 1000)
+	if err = confirmDeletes("test"); err != nil {
+		t.Fatal(err)
+	}
+	l, err = guardDeletes(jobs(4), 1000)
+	if err != nil || len(l) != 5 {
+		t.Errorf("few deletions must not be blocked: %v", err)
+	}
+	l, err = guardDeletes(jobs(51), 1000)
+	if err != nil || len(l) != 52 {
+		t.Errorf("confirmed deletions must not be blocked: %v", err)
+	}
+	_, err = guardDeletes(jobs(51), 1000)
+	if err == nil {
+		t.Error("confirmation must be valid for one sync only")
+	}
+
+	if err = confirmDeletes("test"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = guardDeletes(jobs(52), 1000)
+	if err == nil {
+		t.Error("confirmation must not allow deletions that are not blocked before")
+	}
+}
+
+func TestOnlyDeletesBlocked(t *testing.T) {
+	blocked := fmt.Errorf("cannot sync folder %q: %w", "a", &deletesBlockedError{folder: "a"})
+	other := fmt.Errorf("cannot sync folder %q: %w", "b", errors.New("upload failed"))
+	cases := []struct {
+		err      error
+		expected bool
+	}{
+		{nil, false},
+		{errors.Join(blocked), true},
+		{errors.Join(blocked, blocked), true},
+		{errors.Join(blocked, other), false},
+		{errors.Join(other), false},
+		{fmt.Errorf("cannot sync folder %q: %w", "c", errors.Join(errors.New("upload failed"), &deletesBlockedError{})), false},
+	}
+	for i, c := range cases {
+		if got := onlyDeletesBlocked(c.err); got != c.expected {
+			t.Errorf("case %d: expected %v, got %v", i, c.expected, got)
+		}
+	}
+}
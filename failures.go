@@ -0,0 +1,268 @@
+package putiosync
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cenkalti/log"
+	"github.com/putdotio/putio-sync/v2/internal/api"
+	"go.etcd.io/bbolt"
+)
+
+// bucketFailures contains the failed jobs of a sync pair, keyed by path.
+var bucketFailures = []byte("failures")
+
+const (
+	// Files are quarantined after failing this many times in a row, unless MaxAttempts is set in config.
+	defaultMaxAttempts = 5
+	// Wait time before retrying a failed file. It is doubled after each failure.
+	minRetryDelay = time.Minute
+	maxRetryDelay = 6 * time.Hour
+)
+
+// failureRecord is the record of consecutive failures of jobs for a path.
+type failureRecord struct {
+	// Identifies the versions of local and remote files when the job failed, see fileVersion.
+	// Record is discarded when the file changes, so changed files are retried immediately.
+	Version     string    `json:"version"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"lastError"`
+	LastAttempt time.Time `json:"lastAttempt"`
+	NextRetry   time.Time `json:"nextRetry"`
+	// Quarantined files are not retried until they change or "putio-sync retry" is run.
+	Quarantined bool `json:"quarantined"`
+}
+
+// fileVersions contains the versions of files in the last planned sync, keyed by path.
+// Failure records are saved with these versions.
+var fileVersions map[string]string
+
+// fileVersion identifies the contents of local and remote files of a path.
+func fileVersion(sf *syncFile) string {
+	local, remote := "-", "-"
+	if sf.local != nil {
+		if sf.local.Info().IsDir() {
+			local = "dir"
+		} else {
+			local = fmt.Sprintf("%d-%d", sf.local.Info().Size(), sf.local.Info().ModTime().UnixNano())
+		}
+	}
+	if sf.remote != nil {
+		if sf.remote.PutioFile().IsDir() {
+			remote = fmt.Sprintf("dir-%d", sf.remote.PutioFile().ID)
+		} else {
+			remote = fmt.Sprintf("%d-%d-%s", sf.remote.PutioFile().ID, sf.remote.PutioFile().Size, sf.remote.PutioFile().CRC32)
+		}
+	}
+	return local + "/" + remote
+}
+
+func maxAttempts() int {
+	if cfg.MaxAttempts > 0 {
+		return cfg.MaxAttempts
+	}
+	return defaultMaxAttempts
+}
+
+// retryDelay returns the wait time after the given number of failures.
+func retryDelay(attempts int) time.Duration {
+	d := minRetryDelay
+	for i := 1; i < attempts && d < maxRetryDelay; i++ {
+		d *= 2
+	}
+	if d > maxRetryDelay {
+		d = maxRetryDelay
+	}
+	return d
+}
+
+// readFailures returns the failure records of the current sync pair.
+func readFailures() (map[string]failureRecord, error) {
+	m := make(map[string]failureRecord)
+	err := db.View(func(tx *bbolt.Tx) error {
+		return pairBucket(tx, bucketFailures).ForEach(func(key, val []byte) error {
+			var f failureRecord
+			err := json.Unmarshal(val, &f)
+			if err != nil {
+				return err
+			}
+			m[string(key)] = f
+			return nil
+		})
+	})
+	return m, err
+}
+
+// updateFailure records the result of the finished job for its path.
+// Successful jobs clear the record, failed ones increment the attempts and set the time of next retry.
+func updateFailure(info *jobInfo) error {
+	jobTracker.m.Lock()
+	relpath, state, lastError := info.Path, info.State, info.Error
+	jobTracker.m.Unlock()
+	if state != jobDone && state != jobFailed {
+		return nil
+	}
+	return db.Update(func(tx *bbolt.Tx) error {
+		b := pairBucket(tx, bucketFailures)
+		if state == jobDone {
+			return b.Delete([]byte(relpath))
+		}
+		var f failureRecord
+		if val := b.Get([]byte(relpath)); val != nil {
+			err := json.Unmarshal(val, &f)
+			if err != nil {
+				return err
+			}
+		}
+		version := fileVersions[relpath]
+		if f.Version != version {
+			f = failureRecord{Version: version}
+		}
+		now := time.Now()
+		f.Attempts++
+		f.LastError = lastError
+		f.LastAttempt = now
+		f.NextRetry = now.Add(retryDelay(f.Attempts))
+		if f.Attempts >= maxAttempts() {
+			f.Quarantined = true
+			log.Errorf("Syncing %q failed %d times, it will not be retried until the file changes or \"putio-sync retry\" is run", relpath, f.Attempts)
+		}
+		val, err := json.Marshal(f)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(relpath), val)
+	})
+}
+
+// filterOutFailedJobs removes the jobs of files that failed before and are not due for retry yet.
+// Records of files that have changed since the failure are deleted, so they are retried now.
+// Records of files that do not need syncing anymore are deleted too.
+func filterOutFailedJobs(jobs []iJob, syncFiles map[string]*syncFile) ([]iJob, error) {
+	fileVersions = make(map[string]string, len(syncFiles))
+	for relpath, sf := range syncFiles {
+		fileVersions[relpath] = fileVersion(sf)
+	}
+	failures, err := readFailures()
+	if err != nil {
+		return nil, err
+	}
+	hasJob := make(map[string]bool, len(jobs))
+	for _, job := range jobs {
+		hasJob[job.Paths()[0]] = true
+	}
+	var changed []string
+	for relpath, f := range failures {
+		if !hasJob[relpath] || fileVersions[relpath] != f.Version {
+			changed = append(changed, relpath)
+			delete(failures, relpath)
+		}
+	}
+	if len(changed) > 0 {
+		err = db.Update(func(tx *bbolt.Tx) error {
+			b := pairBucket(tx, bucketFailures)
+			for _, relpath := range changed {
+				if err := b.Delete([]byte(relpath)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	now := time.Now()
+	filtered := jobs[:0]
+	for _, job := range jobs {
+		relpath := job.Paths()[0]
+		f, ok := failures[relpath]
+		switch {
+		case !ok:
+			filtered = append(filtered, job)
+		case f.Quarantined:
+			log.Warningf("Skipping quarantined file %q: %s", relpath, f.LastError)
+		case now.Before(f.NextRetry):
+			log.Infof("Skipping %q until %s after %d failed attempts", relpath, f.NextRetry.Format(time.Kitchen), f.Attempts)
+		default:
+			filtered = append(filtered, job)
+		}
+	}
+	return filtered, nil
+}
+
+// nextRetry returns the earliest time in future that a failed file of any sync pair is due for retry.
+// Past retry times are not returned, they are due already but their jobs could not be run in the last sync.
+func nextRetry() (time.Time, bool) {
+	var next time.Time
+	now := time.Now()
+	err := forEachFailure(func(_, _ string, f failureRecord) {
+		if !f.Quarantined && f.NextRetry.After(now) && (next.IsZero() || f.NextRetry.Before(next)) {
+			next = f.NextRetry
+		}
+	})
+	if err != nil {
+		log.Errorln("cannot read failures:", err.Error())
+	}
+	return next, !next.IsZero()
+}
+
+// listQuarantined returns the quarantined files of all sync pairs.
+func listQuarantined() ([]api.QuarantinedFile, error) {
+	l := []api.QuarantinedFile{}
+	err := forEachFailure(func(name, relpath string, f failureRecord) {
+		if f.Quarantined {
+			l = append(l, api.QuarantinedFile{Folder: name, Path: relpath, Attempts: f.Attempts, LastError: f.LastError, Since: f.LastAttempt})
+		}
+	})
+	return l, err
+}
+
+func forEachFailure(fn func(name, relpath string, f failureRecord)) error {
+	return db.View(func(tx *bbolt.Tx) error {
+		pairs := tx.Bucket(bucketPairs)
+		if pairs == nil {
+			return nil
+		}
+		return pairs.ForEachBucket(func(name []byte) error {
+			b := pairs.Bucket(name).Bucket(bucketFailures)
+			if b == nil {
+				return nil
+			}
+			return b.ForEach(func(key, val []byte) error {
+				var f failureRecord
+				err := json.Unmarshal(val, &f)
+				if err != nil {
+					return err
+				}
+				fn(string(name), string(key), f)
+				return nil
+			})
+		})
+	})
+}
+
+// retryFailed deletes the failure record of the path in the sync pair, so it is synced on next sync.
+// All records of the sync pair are deleted if path is empty.
+func retryFailed(name, relpath string) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		pairs := tx.Bucket(bucketPairs)
+		if pairs == nil {
+			return nil
+		}
+		pair := pairs.Bucket([]byte(name))
+		if pair == nil || pair.Bucket(bucketFailures) == nil {
+			return nil
+		}
+		if relpath != "" {
+			return pair.Bucket(bucketFailures).Delete([]byte(relpath))
+		}
+		err := pair.DeleteBucket(bucketFailures)
+		if err != nil {
+			return err
+		}
+		_, err = pair.CreateBucket(bucketFailures)
+		return err
+	})
+}
@@ -0,0 +1,20 @@
+package putiosync
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	cases := map[int]time.Duration{
+		1:  time.Minute,
+		2:  2 * time.Minute,
+		4:  8 * time.Minute,
+		20: maxRetryDelay,
+	}
+	for attempts, expected := range cases {
+		if d := retryDelay(attempts); d != expected {
+			t.Errorf("%d attempts: expected %s, got %s", attempts, expected, d)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package putiosync
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cenkalti/log"
+	"github.com/putdotio/putio-sync/v2/internal/inode"
+	"go.etcd.io/bbolt"
+)
+
+// bucketHashes contains CRC32 checksums of local files of a sync pair.
+// Keys are made of inode, size and modification time, so a file is hashed again only if it changes.
+var bucketHashes = []byte("hashes")
+
+const defaultHashWorkers = 2
+
+// hashLocalFiles sets the CRC32 checksum of local files that exist on both sides.
+// Files are not hashed if their size tells that they are changed.
+func hashLocalFiles(ctx context.Context, syncFiles map[string]*syncFile) error {
+	type hashItem struct {
+		sf  *syncFile
+		key string
+		sum string
+		err error
+	}
+	var items []*hashItem
+	for _, sf := range syncFiles {
+		if sf.local == nil || sf.remote == nil || sf.local.Info().IsDir() || sf.remote.Info().IsDir() {
+			continue
+		}
+		size := sf.local.Info().Size()
+		if size != sf.remote.PutioFile().Size && (sf.state == nil || size != sf.state.Size) {
+			continue
+		}
+		in, err := inode.Get(sf.local.FullPath(), sf.local.Info())
+		if err != nil {
+			log.Errorln("cannot get inode:", err.Error())
+			continue
+		}
+		key := fmt.Sprintf("%d-%d-%d", in, sf.local.Info().Size(), sf.local.Info().ModTime().UnixNano())
+		items = append(items, &hashItem{sf: sf, key: key})
+	}
+
+	// Read checksums from the cache.
+	var missing []*hashItem
+	err := db.View(func(tx *bbolt.Tx) error {
+		b := pairBucket(tx, bucketHashes)
+		for _, item := range items {
+			if val := b.Get([]byte(item.key)); val != nil {
+				item.sf.localCRC32 = string(val)
+			} else {
+				missing = append(missing, item)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Hash the remaining files in parallel.
+	if len(missing) > 0 {
+		workers := cfg.HashWorkers
+		if workers <= 0 {
+			workers = defaultHashWorkers
+		}
+		itemC := make(chan *hashItem)
+		doneC := make(chan *hashItem)
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for item := range itemC {
+					item.sum, item.err = fileCRC32(ctx, item.sf.local.FullPath())
+					doneC <- item
+				}
+			}()
+		}
+		go func() {
+			defer close(itemC)
+			for _, item := range missing {
+				select {
+				case itemC <- item:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		go func() {
+			wg.Wait()
+			close(doneC)
+		}()
+		var done int
+		for item := range doneC {
+			done++
+			syncStatus = fmt.Sprintf("Hashing local files (%d/%d)", done, len(missing))
+			if item.err != nil {
+				// File may be changed or deleted after walking. It is going to be hashed again in next sync.
+				log.Warningf("Cannot hash local file %q: %s", item.sf.relpath, item.err.Error())
+				continue
+			}
+			item.sf.localCRC32 = item.sum
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	// Replace the cache with checksums of current files, so old entries do not pile up.
+	return db.Update(func(tx *bbolt.Tx) error {
+		pair := tx.Bucket(bucketPairs).Bucket([]byte(folder.Name))
+		err := pair.DeleteBucket(bucketHashes)
+		if err != nil {
+			return err
+		}
+		b, err := pair.CreateBucket(bucketHashes)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			if item.sf.localCRC32 == "" {
+				continue
+			}
+			err = b.Put([]byte(item.key), []byte(item.sf.localCRC32))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// fileCRC32 returns the CRC32 checksum of the file in the format used by Put.io API.
+func fileCRC32(ctx context.Context, name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := crc32.NewIEEE()
+	_, err = io.Copy(h, &contextReader{ctx: ctx, r: f})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%08x", h.Sum32()), nil
+}
+
+// crc32Differs reports whether both checksums are known and they are different.
+func crc32Differs(a, b string) bool {
+	x, err := strconv.ParseUint(strings.TrimSpace(a), 16, 32)
+	if err != nil {
+		return false
+	}
+	y, err := strconv.ParseUint(strings.TrimSpace(b), 16, 32)
+	if err != nil {
+		return false
+	}
+	return x != y
+}
+
+// contextReader stops reading when the context is cancelled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
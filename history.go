@@ -0,0 +1,178 @@
+package putiosync
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/log"
+	"github.com/putdotio/putio-sync/v2/internal/api"
+	"go.etcd.io/bbolt"
+)
+
+// Number of job records kept in history. Oldest records are deleted when it is exceeded.
+const maxHistoryEntries = 10000
+
+var (
+	// bucketHistory contains the records of finished jobs of all sync pairs.
+	// Its sequence is used for numbering sync cycles.
+	bucketHistory = []byte("history")
+	// bucketHistoryJobs is inside bucketHistory and contains records keyed by their ID.
+	bucketHistoryJobs = []byte("jobs")
+)
+
+// cycleID is the number of the current sync cycle. Records of jobs run in the same cycle have the same number.
+var cycleID uint64
+
+// historyQuery selects the records returned by listHistory. Zero values match all records.
+type historyQuery struct {
+	// Matches records of the path and files inside it.
+	Path  string
+	Since time.Time
+}
+
+func (q historyQuery) match(e *api.HistoryEntry) bool {
+	if e.FinishedAt.Before(q.Since) {
+		return false
+	}
+	if q.Path == "" {
+		return true
+	}
+	return isSubpath(e.Path, q.Path) || (e.To != "" && isSubpath(e.To, q.Path))
+}
+
+func isSubpath(p, dir string) bool {
+	dir = path.Clean(dir)
+	return p == dir || strings.HasPrefix(p, dir+"/")
+}
+
+// parseSince parses a time in RFC 3339 format or a duration before now.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, must be in RFC 3339 format or a duration", s)
+	}
+	return now.Add(-d), nil
+}
+
+// startCycle sets cycleID to the next number.
+func startCycle() error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(bucketHistory)
+		if err != nil {
+			return err
+		}
+		cycleID, err = b.NextSequence()
+		return err
+	})
+}
+
+// recordJob saves the finished job in history and writes it to the audit journal if it deletes or moves a file.
+// Errors are only logged, they must not fail the job.
+func recordJob(info *jobInfo) {
+	jobTracker.m.Lock()
+	e := api.HistoryEntry{
+		Cycle:      cycleID,
+		Folder:     info.Folder,
+		Type:       info.Type,
+		Path:       info.Path,
+		To:         info.To,
+		Bytes:      info.BytesDone,
+		Outcome:    info.State,
+		Error:      info.Error,
+		StartedAt:  info.StartedAt,
+		FinishedAt: info.FinishedAt,
+	}
+	jobTracker.m.Unlock()
+	if !e.StartedAt.IsZero() {
+		e.Duration = e.FinishedAt.Sub(e.StartedAt).Seconds()
+	}
+	err := addHistory(&e)
+	if err != nil {
+		log.Errorln("cannot save job history:", err.Error())
+	}
+	err = writeAudit(&e)
+	if err != nil {
+		log.Errorln("cannot write audit journal:", err.Error())
+	}
+}
+
+func addHistory(e *api.HistoryEntry) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		h, err := tx.CreateBucketIfNotExists(bucketHistory)
+		if err != nil {
+			return err
+		}
+		b, err := h.CreateBucketIfNotExists(bucketHistoryJobs)
+		if err != nil {
+			return err
+		}
+		e.ID, err = b.NextSequence()
+		if err != nil {
+			return err
+		}
+		val, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		err = b.Put(historyKey(e.ID), val)
+		if err != nil {
+			return err
+		}
+		if e.ID <= maxHistoryEntries {
+			return nil
+		}
+		// Keys are in increasing order, so the oldest records are at the start.
+		oldest := historyKey(e.ID - maxHistoryEntries)
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k, oldest) <= 0; k, _ = c.First() {
+			err = b.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func historyKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
+
+// listHistory returns the records matching the query, newest first.
+func listHistory(q historyQuery) ([]api.HistoryEntry, error) {
+	l := []api.HistoryEntry{}
+	err := db.View(func(tx *bbolt.Tx) error {
+		h := tx.Bucket(bucketHistory)
+		if h == nil {
+			return nil
+		}
+		b := h.Bucket(bucketHistoryJobs)
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var e api.HistoryEntry
+			err := json.Unmarshal(v, &e)
+			if err != nil {
+				return err
+			}
+			if q.match(&e) {
+				l = append(l, e)
+			}
+		}
+		return nil
+	})
+	return l, err
+}
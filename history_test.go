@@ -0,0 +1,128 @@
+package putiosync
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/putdotio/putio-sync/v2/internal/api"
+	"go.etcd.io/bbolt"
+)
+
+func openTestDB(t *testing.T) {
+	var err error
+	db, err = bbolt.Open(filepath.Join(t.TempDir(), "sync.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.NoSync = true
+	t.Cleanup(func() {
+		db.Close()
+		db = nil
+	})
+}
+
+func TestHistory(t *testing.T) {
+	openTestDB(t)
+	now := time.Now()
+	for i := 0; i < maxHistoryEntries+2; i++ {
+		e := api.HistoryEntry{Type: "download", Path: "a/b", FinishedAt: now.Add(-time.Hour)}
+		switch i {
+		case 0:
+			e.Path = "oldest"
+		case maxHistoryEntries:
+			e.Type, e.Path, e.To, e.FinishedAt = "move-local", "c", "a/d", now
+		case maxHistoryEntries + 1:
+			e.Path, e.FinishedAt = "ab", now
+		}
+		err := addHistory(&e)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	l, err := listHistory(historyQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != maxHistoryEntries {
+		t.Fatalf("expected %d records, got %d", maxHistoryEntries, len(l))
+	}
+	if l[0].Path != "ab" || l[len(l)-1].Path == "oldest" {
+		t.Errorf("records must be newest first and oldest must be deleted: first %q, last %q", l[0].Path, l[len(l)-1].Path)
+	}
+	l, err = listHistory(historyQuery{Path: "a/", Since: now.Add(-time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 1 || l[0].Type != "move-local" {
+		t.Errorf("unexpected records: %+v", l)
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	cases := map[string]time.Time{
+		"2024-04-30T10:00:00Z": time.Date(2024, 4, 30, 10, 0, 0, 0, time.UTC),
+		"24h":                  now.Add(-24 * time.Hour),
+	}
+	for s, expected := range cases {
+		got, err := parseSince(s, now)
+		if err != nil {
+			t.Errorf("%s: %v", s, err)
+			continue
+		}
+		if !got.Equal(expected) {
+			t.Errorf("%s: expected %v, got %v", s, expected, got)
+		}
+	}
+	if _, err := parseSince("yesterday", now); err == nil {
+		t.Error("expected error for invalid time")
+	}
+}
+
+func TestAuditJournal(t *testing.T) {
+	cfg.AuditLog = filepath.Join(t.TempDir(), "audit.log")
+	defer func() { cfg.AuditLog = "" }()
+	err := openAuditJournal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeAuditJournal()
+	jobs := []iJob{
+		&downloadJob{remoteFile: fakeRemoteFile("downloaded")},
+		&overwriteLocalJob{remoteFile: fakeRemoteFile("overwritten")},
+		&deleteLocalFileJob{state: stateType{relpath: "skipped"}},
+	}
+	for _, job := range jobs {
+		e := newPlanEntry(job, nil)
+		outcome := jobDone
+		if e.Path == "skipped" {
+			outcome = jobSkipped
+		}
+		err = writeAudit(&api.HistoryEntry{Type: e.Type, Path: e.Path, Outcome: outcome, FinishedAt: time.Now()})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, err := os.Open(cfg.AuditLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var paths []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r auditRecord
+		err = json.Unmarshal(scanner.Bytes(), &r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, r.Path)
+	}
+	if len(paths) != 1 || paths[0] != "overwritten" {
+		t.Errorf("only the overwrite must be written to the journal, got %v", paths)
+	}
+}
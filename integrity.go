@@ -0,0 +1,37 @@
+package putiosync
+
+import (
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// integrityError is returned from transfer jobs when the checksum of transferred data does not match.
+// Transferred data is discarded and the failure is recorded like other job errors, see updateFailure.
+type integrityError struct {
+	relpath  string
+	expected string
+	actual   string
+}
+
+func (e *integrityError) Error() string {
+	return fmt.Sprintf("checksum mismatch for %q: expected crc32 %s, got %s", e.relpath, e.expected, e.actual)
+}
+
+// seedCRC32 returns a CRC32 hash that has the first n bytes of the file written into it.
+// It is used for verifying resumed transfers.
+func seedCRC32(name string, n int64) (hash.Hash32, error) {
+	h := crc32.NewIEEE()
+	if n == 0 {
+		return h, nil
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	_, err = io.CopyN(h, f, n)
+	return h, err
+}
@@ -0,0 +1,102 @@
+// Package api contains the types in the responses of the control server.
+// They are shared by the server and the command-line client, so both sides use the same JSON fields.
+package api
+
+import "time"
+
+// Status is the body of the response for status request.
+type Status struct {
+	Status      string            `json:"status"`
+	Syncing     bool              `json:"syncing"`
+	Paused      bool              `json:"paused"`
+	Queued      QueueTotals       `json:"queued"`
+	Quarantined []QuarantinedFile `json:"quarantined"`
+}
+
+// QueueTotals is the number and total size of queued jobs.
+type QueueTotals struct {
+	Jobs  int   `json:"jobs"`
+	Bytes int64 `json:"bytes"`
+}
+
+// QuarantinedFile is a file that is not synced anymore after failing repeatedly.
+type QuarantinedFile struct {
+	Folder    string    `json:"folder"`
+	Path      string    `json:"path"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"lastError"`
+	Since     time.Time `json:"since"`
+}
+
+// Job is the state of a running, queued or recently finished job.
+type Job struct {
+	ID          int64     `json:"id"`
+	Folder      string    `json:"folder"`
+	Type        string    `json:"type"`
+	Path        string    `json:"path"`
+	To          string    `json:"to,omitempty"`
+	Description string    `json:"description"`
+	State       string    `json:"state"`
+	Error       string    `json:"error,omitempty"`
+	QueuedAt    time.Time `json:"queuedAt"`
+	StartedAt   time.Time `json:"startedAt"`
+	FinishedAt  time.Time `json:"finishedAt"`
+	// Transfer progress, set only for uploads and downloads.
+	BytesDone  int64 `json:"bytesDone"`
+	BytesTotal int64 `json:"bytesTotal"`
+	// Bytes per second.
+	Speed int64 `json:"speed"`
+	// Estimated seconds until the transfer is finished.
+	ETA int64 `json:"eta"`
+	// Number of times a job is run for the path since the last success, including this one.
+	Attempt int `json:"attempt"`
+	// Error of the previous attempt.
+	LastError string `json:"lastError,omitempty"`
+}
+
+// HistoryEntry is the record of a finished job.
+type HistoryEntry struct {
+	ID     uint64 `json:"id"`
+	Cycle  uint64 `json:"cycle"`
+	Folder string `json:"folder"`
+	Type   string `json:"type"`
+	Path   string `json:"path"`
+	To     string `json:"to,omitempty"`
+	// Number of bytes transferred.
+	Bytes int64 `json:"bytes"`
+	// Seconds between start and finish of the job.
+	Duration   float64   `json:"duration"`
+	Outcome    string    `json:"outcome"`
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+}
+
+// Conflict is a file that is changed on both sides and not resolved yet.
+type Conflict struct {
+	Folder    string        `json:"folder"`
+	Path      string        `json:"path"`
+	Kind      string        `json:"kind"`
+	Local     *ConflictSide `json:"local,omitempty"`
+	Remote    *ConflictSide `json:"remote,omitempty"`
+	FirstSeen time.Time     `json:"firstSeen"`
+	// Action set by the user for resolving the conflict on next sync.
+	Resolution string `json:"resolution,omitempty"`
+}
+
+// ConflictSide contains information about the file on one side of a conflict.
+type ConflictSide struct {
+	IsDir   bool      `json:"isDir"`
+	Size    int64     `json:"size"`
+	CRC32   string    `json:"crc32,omitempty"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// LogEntry is a log message of the running program.
+type LogEntry struct {
+	// Sequence number of the message, used for getting messages after the last seen one.
+	Seq     int64     `json:"seq"`
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+}
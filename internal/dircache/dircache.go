@@ -4,6 +4,7 @@ import (
 	"context"
 	"path"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/cenkalti/log"
@@ -11,11 +12,15 @@ import (
 )
 
 // DirCache holds a map for accessing IDs by path.
+// It is safe for concurrent use.
 type DirCache struct {
 	client         *putio.Client
 	requestTimeout time.Duration
 	remoteFolderID int64
-	m              map[string]int64
+
+	// Lock is held while creating folders, so the same folder is not created twice by concurrent calls.
+	mu sync.Mutex
+	m  map[string]int64
 }
 
 func New(client *putio.Client, requestTimeout time.Duration, remoteFolderID int64) *DirCache {
@@ -28,21 +33,33 @@ func New(client *putio.Client, requestTimeout time.Duration, remoteFolderID int6
 }
 
 func (c *DirCache) Debug() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	for k, v := range c.m {
 		log.Debugln("DirCache", k, v)
 	}
 }
 
 func (c *DirCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.m = make(map[string]int64)
 }
 
 func (c *DirCache) Set(relpath string, id int64) {
 	relpath = strings.TrimRight(relpath, "/")
+	c.mu.Lock()
 	c.m[relpath] = id
+	c.mu.Unlock()
 }
 
 func (c *DirCache) Mkdirp(ctx context.Context, relpath string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.mkdirp(ctx, relpath)
+}
+
+func (c *DirCache) mkdirp(ctx context.Context, relpath string) (int64, error) {
 	relpath = strings.TrimRight(relpath, "/")
 	log.Debugln("DirCache.Mkdirp", relpath)
 	if relpath == "." || relpath == "" {
@@ -52,7 +69,7 @@ func (c *DirCache) Mkdirp(ctx context.Context, relpath string) (int64, error) {
 		return id, nil
 	}
 	dir, base := path.Split(relpath)
-	dirID, err := c.Mkdirp(ctx, dir)
+	dirID, err := c.mkdirp(ctx, dir)
 	if err != nil {
 		return 0, err
 	}
@@ -66,3 +83,15 @@ func (c *DirCache) Mkdirp(ctx context.Context, relpath string) (int64, error) {
 	c.m[relpath] = f.ID
 	return f.ID, nil
 }
+
+// Remove deletes the entries for the folder at relpath and its subfolders.
+func (c *DirCache) Remove(relpath string) {
+	relpath = strings.TrimRight(relpath, "/")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k := range c.m {
+		if k == relpath || strings.HasPrefix(k, relpath+"/") {
+			delete(c.m, k)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+// Package ignore implements matching of file paths against gitignore style rules.
+package ignore
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/cenkalti/log"
+)
+
+// FileName is the name of the file that contains rules for the folder it is in and its subfolders.
+const FileName = ".putioignore"
+
+// Matcher matches slash separated relative paths against the rules given in constructor
+// and the rules in ignore files found in the local dir.
+// Rules in deeper folders take precedence over the rules in upper folders.
+type Matcher struct {
+	root   string
+	global []rule
+
+	m     sync.Mutex
+	rules map[string][]rule // rules in ignore files, keyed by relpath of the folder
+	dirs  map[string]bool   // cached results for folders
+}
+
+// New returns a new Matcher for the dir at root.
+// Patterns are relative to root and have lower precedence than the rules in ignore files.
+func New(root string, patterns []string) *Matcher {
+	return &Matcher{
+		root:   root,
+		global: parseRules(patterns),
+		rules:  make(map[string][]rule),
+		dirs:   make(map[string]bool),
+	}
+}
+
+// Match reports whether the file at relpath is ignored.
+// A file is ignored if one of its parent folders is ignored.
+func (m *Matcher) Match(relpath string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+	relpath = strings.Trim(relpath, "/")
+	if relpath == "" || relpath == "." {
+		return false
+	}
+	m.m.Lock()
+	defer m.m.Unlock()
+	parts := strings.Split(relpath, "/")
+	for i := 1; i < len(parts); i++ {
+		if m.matchDir(strings.Join(parts[:i], "/")) {
+			return true
+		}
+	}
+	if isDir {
+		return m.matchDir(relpath)
+	}
+	return m.match(relpath, false)
+}
+
+func (m *Matcher) matchDir(relpath string) bool {
+	ignored, ok := m.dirs[relpath]
+	if !ok {
+		ignored = m.match(relpath, true)
+		m.dirs[relpath] = ignored
+	}
+	return ignored
+}
+
+func (m *Matcher) match(relpath string, isDir bool) bool {
+	ignored := false
+	apply := func(rules []rule, rel string) {
+		for _, r := range rules {
+			if r.match(rel, isDir) {
+				ignored = !r.negate
+			}
+		}
+	}
+	apply(m.global, relpath)
+	apply(m.load("."), relpath)
+	dir := ""
+	for _, name := range strings.Split(path.Dir(relpath), "/") {
+		if name == "." {
+			break
+		}
+		dir = path.Join(dir, name)
+		apply(m.load(dir), strings.TrimPrefix(relpath, dir+"/"))
+	}
+	return ignored
+}
+
+// load returns the rules in the ignore file in the folder at relpath.
+func (m *Matcher) load(relpath string) []rule {
+	rules, ok := m.rules[relpath]
+	if ok {
+		return rules
+	}
+	f, err := os.Open(filepath.Join(m.root, filepath.FromSlash(relpath), FileName))
+	if err == nil {
+		var lines []string
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		if err = scanner.Err(); err != nil {
+			log.Errorf("Cannot read ignore file in %q: %s", relpath, err)
+		}
+		f.Close()
+		rules = parseRules(lines)
+	} else if !os.IsNotExist(err) {
+		log.Errorf("Cannot open ignore file in %q: %s", relpath, err)
+	}
+	m.rules[relpath] = rules
+	return rules
+}
+
+type rule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+func (r rule) match(relpath string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	return r.re.MatchString(relpath)
+}
+
+func parseRules(lines []string) []rule {
+	rules := make([]rule, 0, len(lines))
+	for _, line := range lines {
+		r, ok := parseRule(line)
+		if ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+func parseRule(line string) (r rule, ok bool) {
+	line = strings.TrimSuffix(line, "\r")
+	if !strings.HasSuffix(line, `\ `) {
+		line = strings.TrimRight(line, " \t")
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return
+	}
+	switch {
+	case strings.HasPrefix(line, "!"):
+		r.negate = true
+		line = line[1:]
+	case strings.HasPrefix(line, `\!`), strings.HasPrefix(line, `\#`):
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return
+	}
+	// Patterns containing a slash are relative to the folder of the ignore file.
+	// Others match the name at any depth.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	re, err := regexp.Compile(globToRegexp(line, anchored))
+	if err != nil {
+		log.Errorf("Invalid ignore pattern %q: %s", line, err)
+		return
+	}
+	r.re = re
+	return r, true
+}
+
+func globToRegexp(p string, anchored bool) string {
+	var b strings.Builder
+	b.WriteString("^")
+	if !anchored {
+		b.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(p); {
+		switch {
+		case strings.HasPrefix(p[i:], "**/") && (i == 0 || p[i-1] == '/'):
+			b.WriteString("(?:.*/)?")
+			i += 3
+		case p[i:] == "**" && (i == 0 || p[i-1] == '/'):
+			b.WriteString(".*")
+			i += 2
+		case p[i] == '*':
+			b.WriteString("[^/]*")
+			i++
+		case p[i] == '?':
+			b.WriteString("[^/]")
+			i++
+		case p[i] == '[':
+			j := strings.IndexByte(p[i+1:], ']')
+			if j <= 0 {
+				b.WriteString(`\[`)
+				i++
+				break
+			}
+			class := p[i+1 : i+1+j]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += j + 2
+		case p[i] == '\\' && i+1 < len(p):
+			b.WriteString(regexp.QuoteMeta(p[i+1 : i+2]))
+			i += 2
+		default:
+			b.WriteString(regexp.QuoteMeta(p[i : i+1]))
+			i++
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
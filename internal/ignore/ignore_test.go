@@ -0,0 +1,50 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	root := t.TempDir()
+	err := os.MkdirAll(filepath.Join(root, "a", "b"), 0777)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(root, FileName), []byte("# comment\n*.log\n!keep.log\nbuild/\n/top.txt\ndocs/**/*.pdf\n"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(root, "a", FileName), []byte("!*.log\nb/c.txt\n"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := New(root, []string{"*.tmp"})
+	cases := []struct {
+		relpath string
+		isDir   bool
+		ignored bool
+	}{
+		{"x.tmp", false, true},
+		{"x.log", false, true},
+		{"sub/x.log", false, true},
+		{"keep.log", false, false},
+		{"build", true, true},
+		{"build", false, false},
+		{"build/out.bin", false, true},
+		{"top.txt", false, true},
+		{"sub/top.txt", false, false},
+		{"docs/x.pdf", false, true},
+		{"docs/1/2/x.pdf", false, true},
+		{"docs/x.txt", false, false},
+		{"a/x.log", false, false},
+		{"a/b/c.txt", false, true},
+		{"b/c.txt", false, false},
+	}
+	for _, c := range cases {
+		if got := m.Match(c.relpath, c.isDir); got != c.ignored {
+			t.Errorf("Match(%q, %v) = %v, expected %v", c.relpath, c.isDir, got, c.ignored)
+		}
+	}
+}
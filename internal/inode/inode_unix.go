@@ -17,3 +17,13 @@ func Get(path string, fi os.FileInfo) (uint64, error) {
 	}
 	return fi.Sys().(*syscall.Stat_t).Ino, nil
 }
+
+// Device returns the ID of the device that contains the file.
+// It changes when a different filesystem is mounted at the path.
+func Device(path string) (uint64, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(fi.Sys().(*syscall.Stat_t).Dev), nil // nolint: unconvert
+}
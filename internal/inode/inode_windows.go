@@ -18,3 +18,19 @@ func Get(path string, _ os.FileInfo) (uint64, error) {
 	}
 	return (uint64(d.FileIndexHigh) << 32) | uint64(d.FileIndexLow), nil
 }
+
+// Device returns the serial number of the volume that contains the file.
+// It changes when a different drive is mounted at the path.
+func Device(path string) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var d syscall.ByHandleFileInformation
+	err = syscall.GetFileInformationByHandle(syscall.Handle(f.Fd()), &d)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(d.VolumeSerialNumber), nil
+}
@@ -16,6 +16,7 @@ type Progress struct {
 	prefix  string
 	counter *ratecounter.RateCounter
 	ticker  *time.Ticker
+	tee     io.Writer
 }
 
 func New(r io.Reader, offset, size int64, prefix string) *Progress {
@@ -28,13 +29,27 @@ func New(r io.Reader, offset, size int64, prefix string) *Progress {
 	}
 }
 
+// Tee writes the bytes read from the underlying reader to w.
+// It is used for calculating checksums while streaming.
+func (r *Progress) Tee(w io.Writer) {
+	r.tee = w
+}
+
 func (r *Progress) Read(p []byte) (int, error) {
 	n, err := r.r.Read(p)
+	if n > 0 && r.tee != nil {
+		_, _ = r.tee.Write(p[:n])
+	}
 	r.counter.Incr(int64(n))
 	atomic.AddInt64(&r.offset, int64(n))
 	return n, err
 }
 
+// Stats returns the number of bytes transferred, total size and current speed in bytes per second.
+func (r *Progress) Stats() (offset, size, speed int64) {
+	return atomic.LoadInt64(&r.offset), r.size, r.counter.Rate()
+}
+
 func (r *Progress) Start() {
 	r.ticker = time.NewTicker(time.Second)
 	go r.run()
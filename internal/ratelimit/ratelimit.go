@@ -0,0 +1,129 @@
+// Package ratelimit implements a token bucket for limiting the bandwidth of transfers.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/putdotio/putio-sync/v2/internal/size"
+)
+
+// Reads are split into chunks of this size, so concurrent readers share the bandwidth evenly.
+const maxChunkSize = 32 << 10
+
+// Limiter is a token bucket that can be shared by multiple readers.
+// The zero value is an unlimited Limiter.
+type Limiter struct {
+	m      sync.Mutex
+	rate   int64
+	tokens float64
+	last   time.Time
+}
+
+// SetRate changes the rate in bytes per second. Zero means unlimited.
+func (l *Limiter) SetRate(rate int64) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	if rate == l.rate {
+		return
+	}
+	l.rate = rate
+	l.tokens = 0
+	l.last = time.Now()
+}
+
+// Rate returns the current rate in bytes per second.
+func (l *Limiter) Rate() int64 {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return l.rate
+}
+
+// WaitN takes n tokens from the bucket, blocking until they are available.
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	l.m.Lock()
+	if l.rate <= 0 {
+		l.m.Unlock()
+		return nil
+	}
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * float64(l.rate)
+	if burst := float64(l.rate); l.tokens > burst {
+		l.tokens = burst
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / float64(l.rate) * float64(time.Second))
+	}
+	l.m.Unlock()
+	if wait == 0 {
+		return nil
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Reader returns a reader that reads from r at the rate of the limiter.
+func (l *Limiter) Reader(ctx context.Context, r io.Reader) io.Reader {
+	return &reader{ctx: ctx, r: r, l: l}
+}
+
+type reader struct {
+	ctx context.Context
+	r   io.Reader
+	l   *Limiter
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if len(p) > maxChunkSize && r.l.Rate() > 0 {
+		p = p[:maxChunkSize]
+	}
+	n, err := r.r.Read(p)
+	if werr := r.l.WaitN(r.ctx, n); werr != nil && err == nil {
+		err = werr
+	}
+	return n, err
+}
+
+// ParseRate parses rates such as "2MB/s", "500KB/s" or "1048576".
+// Units are multiples of 1024. Empty string, "0" and "unlimited" mean no limit.
+func ParseRate(rate string) (int64, error) {
+	s := strings.TrimSpace(rate)
+	if strings.HasSuffix(strings.ToUpper(s), "/S") {
+		s = s[:len(s)-2]
+	}
+	if strings.EqualFold(s, "unlimited") {
+		return 0, nil
+	}
+	n, err := size.Parse(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate: %q", rate)
+	}
+	return n, nil
+}
+
+// FormatRate formats the rate in the format accepted by ParseRate.
+func FormatRate(rate int64) string {
+	switch {
+	case rate <= 0:
+		return "unlimited"
+	case rate%(1<<20) == 0:
+		return fmt.Sprintf("%dMB/s", rate/(1<<20))
+	case rate%(1<<10) == 0:
+		return fmt.Sprintf("%dKB/s", rate/(1<<10))
+	default:
+		return fmt.Sprintf("%dB/s", rate)
+	}
+}
@@ -0,0 +1,152 @@
+// Package schedule parses weekly time windows such as "09:00-18:00 Mon-Fri".
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const minutesPerDay = 24 * 60
+
+var dayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Window is a recurring time range on some days of the week.
+type Window struct {
+	// Minutes since midnight. If end is not after start, the window ends on the next day.
+	start, end int
+	days       [7]bool
+	s          string
+}
+
+// Parse parses a window in "HH:MM-HH:MM Days" format.
+// Both parts are optional: "22:00-06:00" is every night, "Sat-Sun" is all day on weekends.
+// Days are separated by commas and can be ranges, e.g. "Mon-Wed,Fri".
+// A window that ends on the next day belongs to the day it starts.
+func Parse(s string) (Window, error) {
+	w := Window{end: minutesPerDay, s: s}
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return w, fmt.Errorf("invalid window: %q", s)
+	}
+	var hasTime, hasDays bool
+	for _, f := range fields {
+		var err error
+		if strings.Contains(f, ":") {
+			if hasTime {
+				return w, fmt.Errorf("invalid window: %q", s)
+			}
+			hasTime = true
+			w.start, w.end, err = parseTimeRange(f)
+		} else {
+			if hasDays {
+				return w, fmt.Errorf("invalid window: %q", s)
+			}
+			hasDays = true
+			w.days, err = parseDays(f)
+		}
+		if err != nil {
+			return w, fmt.Errorf("invalid window %q: %w", s, err)
+		}
+	}
+	if !hasDays {
+		for i := range w.days {
+			w.days[i] = true
+		}
+	}
+	return w, nil
+}
+
+func parseTimeRange(s string) (start, end int, err error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid time range: %q", s)
+	}
+	start, err = parseClock(from)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err = parseClock(to)
+	return start, end, err
+}
+
+func parseClock(s string) (int, error) {
+	hour, minute, ok := strings.Cut(s, ":")
+	h, herr := parseNumber(hour)
+	m, merr := parseNumber(minute)
+	if !ok || herr != nil || merr != nil || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid time: %q", s)
+	}
+	return h*60 + m, nil
+}
+
+// parseNumber parses a number of one or two digits. Signs, spaces and other characters are not allowed.
+func parseNumber(s string) (int, error) {
+	if len(s) == 0 || len(s) > 2 || strings.Trim(s, "0123456789") != "" {
+		return 0, strconv.ErrSyntax
+	}
+	return strconv.Atoi(s)
+}
+
+func parseDays(s string) (days [7]bool, err error) {
+	for _, part := range strings.Split(s, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		first, err := parseDay(from)
+		if err != nil {
+			return days, err
+		}
+		last := first
+		if isRange {
+			last, err = parseDay(to)
+			if err != nil {
+				return days, err
+			}
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+func parseDay(s string) (time.Weekday, error) {
+	s = strings.ToLower(s)
+	if len(s) >= 3 {
+		if d, ok := dayNames[s[:3]]; ok {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid day: %q", s)
+}
+
+// Contains reports whether t is inside the window.
+func (w Window) Contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return w.days[t.Weekday()] && minute >= w.start && minute < w.end
+	}
+	// Window ends on the next day.
+	if minute >= w.start {
+		return w.days[t.Weekday()]
+	}
+	if minute < w.end {
+		return w.days[(t.Weekday()+6)%7]
+	}
+	return false
+}
+
+func (w Window) String() string {
+	return w.s
+}
@@ -0,0 +1,52 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindow(t *testing.T) {
+	// 2023-01-02 is a Monday.
+	at := func(day int, clock string) time.Time {
+		t, _ := time.Parse("2006-01-02 15:04", "2023-01-0"+string(rune('0'+day))+" "+clock)
+		return t
+	}
+	cases := []struct {
+		window   string
+		t        time.Time
+		contains bool
+	}{
+		{"09:00-18:00 Mon-Fri", at(2, "09:00"), true},
+		{"09:00-18:00 Mon-Fri", at(2, "18:00"), false},
+		{"09:00-18:00 Mon-Fri", at(6, "12:00"), true},
+		{"09:00-18:00 Mon-Fri", at(7, "12:00"), false},
+		{"Mon-Fri 09:00-18:00", at(3, "08:59"), false},
+		{"22:00-06:00", at(4, "23:00"), true},
+		{"22:00-06:00", at(4, "05:59"), true},
+		{"22:00-06:00", at(4, "12:00"), false},
+		{"22:00-06:00 Fri", at(7, "02:00"), true},
+		{"22:00-06:00 Fri", at(6, "02:00"), false},
+		{"Sat,Sun", at(8, "12:00"), true},
+		{"Sat,Sun", at(2, "12:00"), false},
+		{"Fri-Mon", at(2, "00:00"), true},
+		{"Fri-Mon", at(3, "00:00"), false},
+		{"00:00-24:00 Tue", at(3, "23:59"), true},
+	}
+	for _, c := range cases {
+		w, err := Parse(c.window)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w.Contains(c.t) != c.contains {
+			t.Errorf("%q contains %s: expected %v", c.window, c.t.Format("Mon 15:04"), c.contains)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, s := range []string{"", "25:00-26:00", "09:00", "Foo", "09:00-10:00 Mon Tue", "09:00-10:00 10:00-11:00", "8:30pm-10:00", "08:30:15-10:00", "8:3x-10:00", "08:30-+9:00", "08:-10:00"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+// Package size parses human readable data sizes used in config.
+package size
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parse parses sizes such as "10GB", "500K" or "1048576".
+// Units are multiples of 1024. Empty string is parsed as zero.
+func Parse(size string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	if s == "" {
+		return 0, nil
+	}
+	multiplier := int64(1)
+	for _, u := range []struct {
+		suffix string
+		value  int64
+	}{{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(s, u.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, u.suffix))
+			multiplier = u.value
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid size: %q", size)
+	}
+	return int64(f * float64(multiplier)), nil
+}
@@ -0,0 +1,344 @@
+// Package trash keeps deleted local files in a folder inside the synced dir, so they can be restored later.
+package trash
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Name of the trash folder in synced dir. It is excluded from sync.
+const Name = ".putio-sync-trash"
+
+const (
+	indexName = "index.json"
+	// Lock file is held while the index is changed, so processes running on the same trash do not overwrite each other's changes.
+	lockName = "index.lock"
+)
+
+// ErrNotFound is returned when there is no item at the given path in trash.
+var ErrNotFound = errors.New("item not found in trash")
+
+// Item is a file or folder in trash.
+type Item struct {
+	// Path in trash folder, e.g. "2006-01-02/foo/bar.txt".
+	Path string `json:"path"`
+	// Original path relative to synced dir.
+	OrigPath  string    `json:"origPath"`
+	DeletedAt time.Time `json:"deletedAt"`
+	// Total size of files in the item.
+	Size  int64 `json:"size"`
+	IsDir bool  `json:"isDir"`
+}
+
+// Trash is the trash folder of a synced dir.
+// It is safe for concurrent use, also by other processes, e.g. the running sync and the trash command.
+type Trash struct {
+	root string
+	dir  string
+	m    sync.Mutex
+}
+
+// New returns the trash of the synced dir at root.
+func New(root string) *Trash {
+	return &Trash{
+		root: root,
+		dir:  filepath.Join(root, Name),
+	}
+}
+
+// Move moves the file at relpath in synced dir into trash.
+func (t *Trash) Move(relpath string, now time.Time) error {
+	unlock, err := t.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	src := filepath.Join(t.root, filepath.FromSlash(relpath))
+	fi, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	size, err := diskUsage(src, fi)
+	if err != nil {
+		return err
+	}
+	items, err := t.readIndex()
+	if err != nil {
+		return err
+	}
+	// Add a number to the name if the same path is deleted more than once in a day.
+	dateDir := now.Format("2006-01-02")
+	itemPath := path.Join(dateDir, relpath)
+	for i := 2; ; i++ {
+		_, err = os.Lstat(t.fullPath(itemPath))
+		if os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		itemPath = path.Join(dateDir, fmt.Sprintf("%s (%d)", relpath, i))
+	}
+	dst := t.fullPath(itemPath)
+	err = os.MkdirAll(filepath.Dir(dst), 0777)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(src, dst)
+	if err != nil {
+		return err
+	}
+	items = append(items, Item{
+		Path:      itemPath,
+		OrigPath:  relpath,
+		DeletedAt: now,
+		Size:      size,
+		IsDir:     fi.IsDir(),
+	})
+	return t.writeIndex(items)
+}
+
+// List returns the items in trash, oldest first.
+func (t *Trash) List() ([]Item, error) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	return t.readIndex()
+}
+
+// Restore moves the item back to its original path.
+// If origPath is not empty, the item is moved there instead.
+func (t *Trash) Restore(itemPath, origPath string) error {
+	if !t.exists() {
+		return ErrNotFound
+	}
+	unlock, err := t.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	items, err := t.readIndex()
+	if err != nil {
+		return err
+	}
+	i := findItem(items, itemPath)
+	if i < 0 {
+		return ErrNotFound
+	}
+	if origPath == "" {
+		origPath = items[i].OrigPath
+	}
+	dst := filepath.Join(t.root, filepath.FromSlash(origPath))
+	_, err = os.Lstat(dst)
+	if err == nil {
+		return fmt.Errorf("file already exists at %q", origPath)
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(dst), 0777)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(t.fullPath(itemPath), dst)
+	if err != nil {
+		return err
+	}
+	items = append(items[:i], items[i+1:]...)
+	return t.writeIndex(items)
+}
+
+// Empty deletes all items in trash.
+func (t *Trash) Empty() error {
+	if !t.exists() {
+		return nil
+	}
+	unlock, err := t.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	entries, err := os.ReadDir(t.dir)
+	if err != nil {
+		return err
+	}
+	// Lock file is kept, it is removed when the trash folder is removed.
+	for _, e := range entries {
+		if e.Name() == lockName {
+			continue
+		}
+		err = os.RemoveAll(filepath.Join(t.dir, e.Name()))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Prune deletes items older than maxAge, then deletes oldest items until total size is below maxSize.
+// Zero values mean no limit.
+func (t *Trash) Prune(maxAge time.Duration, maxSize int64, now time.Time) error {
+	if !t.exists() {
+		return nil
+	}
+	unlock, err := t.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	items, err := t.readIndex()
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, item := range items {
+		total += item.Size
+	}
+	remaining := items[:0]
+	for _, item := range items {
+		expired := maxAge > 0 && now.Sub(item.DeletedAt) > maxAge
+		if expired || (maxSize > 0 && total > maxSize) {
+			err = os.RemoveAll(t.fullPath(item.Path))
+			if err != nil {
+				return err
+			}
+			total -= item.Size
+			continue
+		}
+		remaining = append(remaining, item)
+	}
+	if len(remaining) == len(items) {
+		return nil
+	}
+	t.removeEmptyDirs()
+	return t.writeIndex(remaining)
+}
+
+// removeEmptyDirs removes folders left after their items are deleted from trash.
+func (t *Trash) removeEmptyDirs() {
+	var dirs []string
+	_ = filepath.WalkDir(t.dir, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() && p != t.dir {
+			dirs = append(dirs, p)
+		}
+		return nil
+	})
+	// Remove children before parents. Non-empty folders are not removed.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		_ = os.Remove(dirs[i])
+	}
+}
+
+// lock locks the trash for changing its index. Returned function must be called for unlocking.
+func (t *Trash) lock() (func(), error) {
+	t.m.Lock()
+	err := os.MkdirAll(t.dir, 0777)
+	if err != nil {
+		t.m.Unlock()
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(t.dir, lockName), os.O_CREATE|os.O_RDWR, 0666)
+	if err != nil {
+		t.m.Unlock()
+		return nil, err
+	}
+	err = lockFile(f)
+	if err != nil {
+		f.Close()
+		t.m.Unlock()
+		return nil, err
+	}
+	return func() {
+		_ = unlockFile(f)
+		f.Close()
+		t.m.Unlock()
+	}, nil
+}
+
+func (t *Trash) exists() bool {
+	_, err := os.Stat(t.dir)
+	return err == nil
+}
+
+func (t *Trash) fullPath(itemPath string) string {
+	return filepath.Join(t.dir, filepath.FromSlash(itemPath))
+}
+
+func (t *Trash) readIndex() ([]Item, error) {
+	var items []Item
+	b, err := os.ReadFile(filepath.Join(t.dir, indexName))
+	if os.IsNotExist(err) {
+		return items, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, &items)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].DeletedAt.Before(items[j].DeletedAt) })
+	return items, nil
+}
+
+func (t *Trash) writeIndex(items []Item) error {
+	if len(items) == 0 {
+		err := os.Remove(filepath.Join(t.dir, indexName))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	b, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(t.dir, 0777)
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first, so the index is not corrupted if the program stops while writing.
+	tmp := filepath.Join(t.dir, indexName+".tmp")
+	err = os.WriteFile(tmp, b, 0666)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(t.dir, indexName))
+}
+
+func findItem(items []Item, itemPath string) int {
+	for i, item := range items {
+		if item.Path == itemPath {
+			return i
+		}
+	}
+	return -1
+}
+
+func diskUsage(name string, fi os.FileInfo) (int64, error) {
+	if !fi.IsDir() {
+		return fi.Size(), nil
+	}
+	var total int64
+	err := filepath.WalkDir(name, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
@@ -0,0 +1,120 @@
+package trash
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTrash(t *testing.T) {
+	root := t.TempDir()
+	write := func(relpath string, size int) {
+		name := filepath.Join(root, filepath.FromSlash(relpath))
+		if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, make([]byte, size), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tr := New(root)
+	day1 := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+
+	write("a/foo.txt", 10)
+	write("a/bar.txt", 20)
+	write("baz.txt", 30)
+	if err := tr.Move("a/foo.txt", day1); err != nil {
+		t.Fatal(err)
+	}
+	write("a/foo.txt", 5)
+	if err := tr.Move("a/foo.txt", day1); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Move("a", day2); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Move("baz.txt", day2); err != nil {
+		t.Fatal(err)
+	}
+	items, err := tr.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"2023-01-02/a/foo.txt", "2023-01-02/a/foo.txt (2)", "2023-01-03/a", "2023-01-03/baz.txt"}
+	if len(items) != len(expected) {
+		t.Fatalf("unexpected items: %v", items)
+	}
+	for i, item := range items {
+		if item.Path != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], item.Path)
+		}
+	}
+	if items[2].Size != 20 || !items[2].IsDir {
+		t.Errorf("unexpected folder item: %+v", items[2])
+	}
+
+	if err = tr.Restore("2023-01-02/a/foo.txt (2)", ""); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(filepath.Join(root, "a", "foo.txt")); err != nil || fi.Size() != 5 {
+		t.Errorf("file is not restored: %v", err)
+	}
+	if err = tr.Restore("2023-01-02/a/foo.txt", ""); err == nil {
+		t.Error("restore must not overwrite existing files")
+	}
+
+	// Older item is deleted by age, then oldest items are deleted by size.
+	if err = tr.Prune(24*time.Hour, 40, day2.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	items, _ = tr.List()
+	if len(items) != 1 || items[0].Path != "2023-01-03/baz.txt" {
+		t.Errorf("unexpected items after prune: %v", items)
+	}
+	if _, err = os.Stat(filepath.Join(root, Name, "2023-01-02")); !os.IsNotExist(err) {
+		t.Error("empty folders must be removed")
+	}
+}
+
+func TestTrashConcurrent(t *testing.T) {
+	root := t.TempDir()
+	const n = 50
+	for i := 0; i < 2*n; i++ {
+		if err := os.WriteFile(filepath.Join(root, fmt.Sprintf("file%d", i)), nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Separate instances do not share the mutex, like the trash command and the running sync.
+	var wg sync.WaitGroup
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		tr := New(root)
+		wg.Add(1)
+		go func(start int) {
+			defer wg.Done()
+			for j := start; j < start+n; j++ {
+				if err := tr.Move(fmt.Sprintf("file%d", j), now); err != nil {
+					t.Error(err)
+				}
+			}
+		}(i * n)
+	}
+	wg.Wait()
+	items, err := New(root).List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2*n {
+		t.Errorf("expected %d items, got %d", 2*n, len(items))
+	}
+	if err = New(root).Empty(); err != nil {
+		t.Fatal(err)
+	}
+	if items, _ = New(root).List(); len(items) != 0 {
+		t.Errorf("trash is not empty: %v", items)
+	}
+}
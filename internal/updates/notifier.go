@@ -12,6 +12,19 @@ import (
 	"github.com/putdotio/putio-sync/v2/internal/websocket"
 )
 
+// Maximum number of events kept until they are taken with TakeEvents.
+// Events are dropped when it is exceeded and TakeEvents reports them as incomplete.
+const maxPendingEvents = 10000
+
+// Event is a change of a remote file received from the websocket.
+type Event struct {
+	Type string
+	ID   int64
+	// Parent folder of the file after the change. Zero if it is not included in the event.
+	ParentID int64
+	Name     string
+}
+
 type Notifier struct {
 	HasUpdates chan string
 
@@ -25,7 +38,13 @@ type Notifier struct {
 	token     string
 	started   bool
 	connected int32
-	watcher   *FileWatcher
+	watchers  map[*FileWatcher]struct{}
+	// Events received since the last call of TakeEvents.
+	events []Event
+	// Set when some events may not be received, e.g. while disconnected.
+	missed bool
+	// Set when the connection is authenticated, events are received after that.
+	receiving bool
 }
 
 func NewNotifier(wsURL string, handshakeTimeout, writeTimeout time.Duration) *Notifier {
@@ -36,6 +55,8 @@ func NewNotifier(wsURL string, handshakeTimeout, writeTimeout time.Duration) *No
 		writeTimeout:     writeTimeout,
 		newConnectionC:   make(chan *websocket.Websocket),
 		closeC:           make(chan struct{}),
+		watchers:         make(map[*FileWatcher]struct{}),
+		missed:           true,
 	}
 }
 
@@ -75,28 +96,70 @@ func (s *Notifier) Connected() bool {
 	return atomic.LoadInt32(&s.connected) == 1
 }
 
+// WatchFile returns a watcher that is notified when the remote file with id changes.
+// Multiple files can be watched at the same time.
 func (s *Notifier) WatchFile(ctx context.Context, id int64) *FileWatcher {
 	s.m.Lock()
 	defer s.m.Unlock()
 
-	s.watcher = newFileWatcher(ctx, id, func() bool {
+	var w *FileWatcher
+	w = newFileWatcher(ctx, id, func() bool {
 		s.m.Lock()
 		defer s.m.Unlock()
-		if s.watcher == nil {
+		if _, ok := s.watchers[w]; !ok {
 			return false
 		}
-		modified := s.watcher.modified
-		s.watcher.cancel()
-		s.watcher = nil
-		return modified
+		delete(s.watchers, w)
+		w.cancel()
+		return w.modified
 	})
+	s.watchers[w] = struct{}{}
 
-	return s.watcher
+	return w
+}
+
+// TakeEvents returns the events received since the last call and clears them.
+// Returned bool is false if some events may have been missed since the last call,
+// because the connection was down or there were too many events.
+func (s *Notifier) TakeEvents() ([]Event, bool) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	events, complete := s.events, !s.missed
+	s.events = nil
+	s.missed = !s.receiving
+	return events, complete
+}
+
+func (s *Notifier) addEvent(e Event) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.missed {
+		// Events are not complete anyway until they are taken.
+		return
+	}
+	if len(s.events) >= maxPendingEvents {
+		s.events = nil
+		s.missed = true
+		return
+	}
+	s.events = append(s.events, e)
+}
+
+// setReceiving sets whether events are received from the connection.
+func (s *Notifier) setReceiving(receiving bool) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.receiving = receiving
+	if !receiving {
+		s.missed = true
+	}
 }
 
 func (s *Notifier) notifyUpdate(id int64, name string) {
 	s.m.Lock()
-	s.watcher.notify(id)
+	for w := range s.watchers {
+		w.notify(id)
+	}
 	s.m.Unlock()
 	if name == "" {
 		name = strconv.FormatInt(id, 10)
@@ -127,6 +190,7 @@ func (s *Notifier) writer() {
 				ws = nil
 				break
 			}
+			s.setReceiving(true)
 			s.notifyUpdate(-1, "WEBSOCKET_CONNECTED")
 		case <-s.closeC:
 			if ws != nil {
@@ -153,7 +217,10 @@ func (s *Notifier) reader() {
 	}
 
 	atomic.StoreInt32(&s.connected, 1)
-	defer func() { atomic.StoreInt32(&s.connected, 0) }()
+	defer func() {
+		atomic.StoreInt32(&s.connected, 0)
+		s.setReceiving(false)
+	}()
 
 	// Make sure connection is closed on return
 	closed := make(chan struct{})
@@ -187,13 +254,15 @@ func (s *Notifier) reader() {
 			var val eventValue
 			_ = json.Unmarshal(msg.Value, &val)
 			log.Debugf("Remote event received: %s - %d - %s", msg.Type, val.ID, val.Name)
+			s.addEvent(Event{Type: msg.Type, ID: val.ID, ParentID: val.ParentID, Name: val.Name})
 			s.notifyUpdate(val.ID, val.Name)
 		}
 	}
 }
 
-// ID is always included in event details but Name may not be present.
+// ID is always included in event details but ParentID and Name may not be present.
 type eventValue struct {
-	ID   int64  `json:"id"`
-	Name string `json:"name"`
+	ID       int64  `json:"id"`
+	ParentID int64  `json:"parent_id"`
+	Name     string `json:"name"`
 }
@@ -36,7 +36,8 @@ type RemoteFile struct {
 	relpath   string
 }
 
-func newRemoteFile(pf putio.File, relpath string) *RemoteFile {
+// NewRemoteFile returns a file at relpath for the put.io file.
+func NewRemoteFile(pf putio.File, relpath string) *RemoteFile {
 	return &RemoteFile{
 		putioFile: pf,
 		relpath:   relpath,
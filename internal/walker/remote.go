@@ -2,7 +2,10 @@ package walker
 
 import (
 	"context"
+	"errors"
+	"net/http"
 	"path"
+	"path/filepath"
 	"sort"
 	"time"
 
@@ -13,9 +16,15 @@ type remoteWalker struct {
 	root           int64
 	client         *putio.Client
 	requestTimeout time.Duration
+	// If not nil, only these folders are listed, see Walker.RemoteDirs.
+	dirs  []RemoteDir
+	known func(relpath string, id int64) bool
 }
 
 func (w *remoteWalker) Walk(walkFn walkFunc) error {
+	if w.dirs != nil {
+		return w.walkDirs(walkFn)
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), w.requestTimeout)
 	defer cancel()
 	dir, err := w.client.Files.Get(ctx, w.root)
@@ -25,24 +34,50 @@ func (w *remoteWalker) Walk(walkFn walkFunc) error {
 	return w.walk(".", dir, walkFn)
 }
 
+func (w *remoteWalker) walkDirs(walkFn walkFunc) error {
+	for _, d := range w.dirs {
+		ctx, cancel := context.WithTimeout(context.Background(), w.requestTimeout)
+		dir, err := w.client.Files.Get(ctx, d.ID)
+		cancel()
+		var errResp *putio.ErrorResponse
+		if errors.As(err, &errResp) && errResp.Response.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if d.RelPath != "." && (dir.ParentID != d.ParentID || dir.Name != path.Base(d.RelPath)) {
+			continue
+		}
+		err = w.walk(d.RelPath, dir, walkFn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (w *remoteWalker) walk(relpath string, parent putio.File, walkFn walkFunc) error {
+	err := walkFn(NewRemoteFile(parent, relpath), nil)
+	if err == filepath.SkipDir {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), w.requestTimeout)
 	defer cancel()
 	children, _, err := w.client.Files.List(ctx, parent.ID)
 	if err != nil {
 		return walkFn(nil, err)
 	}
-	err = walkFn(newRemoteFile(parent, relpath), nil)
-	if err != nil {
-		return err
-	}
 	// List remote files sorted by ID ascending, this will make sure that latest uploaded version is always the most recent
 	sort.Slice(children, func(i, j int) bool {
 		return children[i].ID < children[j].ID
 	})
 	for _, child := range children {
 		if !child.IsDir() {
-			err = walkFn(newRemoteFile(child, path.Join(relpath, child.Name)), nil)
+			err = walkFn(NewRemoteFile(child, path.Join(relpath, child.Name)), nil)
 			if err != nil {
 				return err
 			}
@@ -50,7 +85,16 @@ func (w *remoteWalker) walk(relpath string, parent putio.File, walkFn walkFunc)
 	}
 	for _, child := range children {
 		if child.IsDir() {
-			err = w.walk(path.Join(relpath, child.Name), child, walkFn)
+			childPath := path.Join(relpath, child.Name)
+			if w.known != nil && w.known(childPath, child.ID) {
+				// Contents are not changed, only the folder itself is returned.
+				err = walkFn(NewRemoteFile(child, childPath), nil)
+				if err == filepath.SkipDir {
+					continue
+				}
+			} else {
+				err = w.walk(childPath, child, walkFn)
+			}
 			if err != nil {
 				return err
 			}
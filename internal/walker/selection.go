@@ -0,0 +1,47 @@
+package walker
+
+import (
+	"path"
+	"strings"
+)
+
+// Selection is a list of slash separated relative paths of folders to be synced.
+// An empty Selection contains all files.
+type Selection []string
+
+// NewSelection returns a Selection with cleaned paths.
+func NewSelection(paths []string) Selection {
+	s := make(Selection, 0, len(paths))
+	for _, p := range paths {
+		p = path.Clean(strings.Trim(p, "/"))
+		if p == "." {
+			// Root folder is selected, everything is included.
+			return nil
+		}
+		s = append(s, p)
+	}
+	return s
+}
+
+// Contains reports whether relpath is one of the selected folders or inside one of them.
+func (s Selection) Contains(relpath string) bool {
+	if len(s) == 0 {
+		return true
+	}
+	for _, p := range s {
+		if relpath == p || strings.HasPrefix(relpath, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// IsParent reports whether relpath is a parent folder of one of the selected folders.
+func (s Selection) IsParent(relpath string) bool {
+	for _, p := range s {
+		if strings.HasPrefix(p, relpath+"/") {
+			return true
+		}
+	}
+	return false
+}
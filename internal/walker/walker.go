@@ -2,55 +2,117 @@ package walker
 
 import (
 	"context"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
 
 	"github.com/cenkalti/log"
 	"github.com/putdotio/go-putio"
+	"github.com/putdotio/putio-sync/v2/internal/ignore"
 )
 
+// Files that are never synced.
 var ignoredFiles = regexp.MustCompile(`(?i)(^|/)(desktop\.ini|thumbs\.db|\.ds_store|icon\r)$`)
 
 type walker interface {
 	Walk(walkFn walkFunc) error
 }
 
+// walkFunc is called for each file during walk.
+// If the function returns filepath.SkipDir for a folder, the contents of the folder are skipped.
 type walkFunc func(file file, err error) error
 
 type Walker struct {
 	LocalPath      string
 	RemoteFolderID int64
 	TempDirName    string
+	// Deleted files are kept in this folder, it is not synced.
+	TrashDirName string
+	// Marker file of the synced dir, it is not synced. Files with the same name in subfolders are synced.
+	RootMarkerName string
 	Client         *putio.Client
 	RequestTimeout time.Duration
+	// Files matching the rules are not returned.
+	Ignore *ignore.Matcher
+	// Only files in selected folders and their parent folders are returned.
+	Include Selection
+	// Sides that do not exist yet are not walked and have no files, e.g. when a plan is made before first sync.
+	SkipLocal  bool
+	SkipRemote bool
+	// If not nil, only these remote folders are listed instead of walking the whole remote tree.
+	// Their subfolders are walked too, unless KnownRemoteDir returns true for them.
+	RemoteDirs     []RemoteDir
+	KnownRemoteDir func(relpath string, id int64) bool
+	// Relative paths of the files that are skipped due to Ignore rules on each side.
+	// Contents of ignored folders are not included. Set after Walk returns.
+	LocalIgnored  []string
+	RemoteIgnored []string
+	// Time spent for walking each side. Set after Walk returns.
+	LocalDuration  time.Duration
+	RemoteDuration time.Duration
+}
+
+// RemoteDir is a remote folder to be listed when only some of the remote folders are listed.
+type RemoteDir struct {
+	RelPath string
+	ID      int64
+	// Folder is not listed if it has been moved, renamed or deleted.
+	// Its new location must be listed instead.
+	ParentID int64
+}
+
+type walkResult struct {
+	files    []file
+	ignored  []string
+	duration time.Duration
 }
 
 func (w *Walker) Walk(ctx context.Context) (localFiles []*LocalFile, remoteFiles []*RemoteFile, err error) {
-	localFilesC := make(chan []file, 1)
-	remoteFilesC := make(chan []file, 1)
+	localFilesC := make(chan walkResult, 1)
+	remoteFilesC := make(chan walkResult, 1)
 	errC := make(chan error, 2)
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
-	go w.walkAsync(ctx, &localWalker{root: w.LocalPath}, localFilesC, errC)
-	go w.walkAsync(ctx, &remoteWalker{root: w.RemoteFolderID, client: w.Client, requestTimeout: w.RequestTimeout}, remoteFilesC, errC)
+	w.LocalIgnored, w.RemoteIgnored = nil, nil
+	rw := &remoteWalker{
+		root:           w.RemoteFolderID,
+		client:         w.Client,
+		requestTimeout: w.RequestTimeout,
+		dirs:           w.RemoteDirs,
+		known:          w.KnownRemoteDir,
+	}
+	if w.SkipLocal {
+		localFilesC <- walkResult{}
+	} else {
+		go w.walkAsync(ctx, &localWalker{root: w.LocalPath}, localFilesC, errC)
+	}
+	if w.SkipRemote {
+		remoteFilesC <- walkResult{}
+	} else {
+		go w.walkAsync(ctx, rw, remoteFilesC, errC)
+	}
 	for {
 		if localFiles != nil && remoteFiles != nil {
 			return localFiles, remoteFiles, nil
 		}
 		select {
-		case files := <-localFilesC:
+		case res := <-localFilesC:
 			log.Debug("Fetched local filesystem tree")
-			localFiles = make([]*LocalFile, 0, len(files))
-			for _, f := range files {
+			localFiles = make([]*LocalFile, 0, len(res.files))
+			for _, f := range res.files {
 				localFiles = append(localFiles, f.(*LocalFile))
 			}
-		case files := <-remoteFilesC:
+			w.LocalIgnored = res.ignored
+			w.LocalDuration = res.duration
+		case res := <-remoteFilesC:
 			log.Debug("Fetched remote filesystem tree")
-			remoteFiles = make([]*RemoteFile, 0, len(files))
-			for _, f := range files {
+			remoteFiles = make([]*RemoteFile, 0, len(res.files))
+			for _, f := range res.files {
 				remoteFiles = append(remoteFiles, f.(*RemoteFile))
 			}
+			w.RemoteIgnored = res.ignored
+			w.RemoteDuration = res.duration
 		case err = <-errC:
 			// Cancel ongoing walk operation on first error
 			cancel()
@@ -59,17 +121,19 @@ func (w *Walker) Walk(ctx context.Context) (localFiles []*LocalFile, remoteFiles
 	}
 }
 
-func (w *Walker) walkAsync(ctx context.Context, walker walker, filesC chan []file, errC chan error) {
-	files, err := w.walkOnFolder(ctx, walker)
+func (w *Walker) walkAsync(ctx context.Context, walker walker, resultC chan walkResult, errC chan error) {
+	start := time.Now()
+	files, ignored, err := w.walkOnFolder(ctx, walker)
 	if err != nil {
 		errC <- err
 		return
 	}
-	filesC <- files
+	resultC <- walkResult{files: files, ignored: ignored, duration: time.Since(start)}
 }
 
-func (w *Walker) walkOnFolder(ctx context.Context, walker walker) ([]file, error) {
+func (w *Walker) walkOnFolder(ctx context.Context, walker walker) ([]file, []string, error) {
 	var l []file
+	var ignored []string
 	fn := func(file file, err error) error {
 		if err != nil {
 			return err
@@ -85,13 +149,35 @@ func (w *Walker) walkOnFolder(ctx context.Context, walker walker) ([]file, error
 		if strings.HasPrefix(file.RelPath(), w.TempDirName) {
 			return nil
 		}
+		if w.TrashDirName != "" && (file.RelPath() == w.TrashDirName || strings.HasPrefix(file.RelPath(), w.TrashDirName+"/")) {
+			if file.Info().IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if w.RootMarkerName != "" && file.RelPath() == w.RootMarkerName {
+			return nil
+		}
 		if Ignored(file.Info().Name()) {
 			return nil
 		}
+		if !w.Include.Contains(file.RelPath()) && !w.Include.IsParent(file.RelPath()) {
+			if file.Info().IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if w.Ignore.Match(file.RelPath(), file.Info().IsDir()) {
+			ignored = append(ignored, file.RelPath())
+			if file.Info().IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
 		l = append(l, file)
 		return nil
 	}
-	return l, walker.Walk(fn)
+	return l, ignored, walker.Walk(fn)
 }
 
 func Ignored(name string) bool {
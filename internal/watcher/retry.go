@@ -2,16 +2,19 @@ package watcher
 
 import (
 	"context"
+	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/cenkalti/log"
+	"github.com/putdotio/putio-sync/v2/internal/ignore"
 	"github.com/putdotio/putio-sync/v2/internal/tmpdir"
+	"github.com/putdotio/putio-sync/v2/internal/trash"
 	"github.com/putdotio/putio-sync/v2/internal/walker"
 )
 
-func retry(ctx context.Context, dir string, watchFn func(ctx context.Context, dir string) (chan string, error)) (chan string, error) {
+func retry(ctx context.Context, dir string, ignorePatterns []string, watchFn func(ctx context.Context, dir string) (chan string, error)) (chan string, error) {
 	in, err := watchFn(ctx, dir)
 	if err != nil {
 		return nil, err
@@ -20,6 +23,7 @@ func retry(ctx context.Context, dir string, watchFn func(ctx context.Context, di
 	// watch started successfully. Wait for channel close event for errors and restart watching.
 	out := make(chan string, 1)
 	go func() {
+		matcher := ignore.New(dir, ignorePatterns)
 		for {
 			select {
 			case event, ok := <-in:
@@ -38,7 +42,13 @@ func retry(ctx context.Context, dir string, watchFn func(ctx context.Context, di
 
 				// This is not the correct place for filtering path names,
 				// but for now it is okay because this `retry` function is used in all implementations.
-				if walker.Ignored(filepath.Base(event)) || strings.Contains(event, tmpdir.Name) {
+				if walker.Ignored(filepath.Base(event)) || strings.Contains(event, tmpdir.Name) || strings.Contains(event, trash.Name) {
+					continue
+				}
+				if filepath.Base(event) == ignore.FileName {
+					// Rules may have changed, reload them.
+					matcher = ignore.New(dir, ignorePatterns)
+				} else if isIgnored(matcher, dir, event) {
 					continue
 				}
 
@@ -57,3 +67,19 @@ func retry(ctx context.Context, dir string, watchFn func(ctx context.Context, di
 
 	return out, nil
 }
+
+// isIgnored reports whether the path in the event matches the ignore rules.
+// Event path may be absolute or relative to the watched dir depending on the platform.
+func isIgnored(m *ignore.Matcher, dir, event string) bool {
+	relpath := event
+	if filepath.IsAbs(event) {
+		var err error
+		relpath, err = filepath.Rel(dir, event)
+		if err != nil || strings.HasPrefix(relpath, "..") {
+			return false
+		}
+	}
+	fi, err := os.Lstat(filepath.Join(dir, relpath))
+	isDir := err == nil && fi.IsDir()
+	return m.Match(filepath.ToSlash(relpath), isDir)
+}
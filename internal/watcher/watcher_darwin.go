@@ -12,8 +12,8 @@ const Recursive = true
 
 const mask = fsevents.ItemCreated | fsevents.ItemRemoved | fsevents.ItemRenamed | fsevents.ItemModified | fsevents.ItemInodeMetaMod
 
-func Watch(ctx context.Context, dir string) (chan string, error) {
-	return retry(ctx, dir, watch)
+func Watch(ctx context.Context, dir string, ignorePatterns []string) (chan string, error) {
+	return retry(ctx, dir, ignorePatterns, watch)
 }
 
 func watch(ctx context.Context, dir string) (chan string, error) {
@@ -11,8 +11,8 @@ const Recursive = false
 
 const mask = fsnotify.Create | fsnotify.Write | fsnotify.Remove | fsnotify.Rename
 
-func Watch(ctx context.Context, dir string) (chan string, error) {
-	return retry(ctx, dir, watch)
+func Watch(ctx context.Context, dir string, ignorePatterns []string) (chan string, error) {
+	return retry(ctx, dir, ignorePatterns, watch)
 }
 
 func watch(ctx context.Context, dir string) (chan string, error) {
@@ -16,8 +16,8 @@ const bufferSize = 32 << 10
 
 const mask = syscall.FILE_NOTIFY_CHANGE_SIZE | syscall.FILE_NOTIFY_CHANGE_FILE_NAME | syscall.FILE_NOTIFY_CHANGE_DIR_NAME | syscall.FILE_NOTIFY_CHANGE_LAST_WRITE
 
-func Watch(ctx context.Context, dir string) (chan string, error) {
-	return retry(ctx, dir, watch)
+func Watch(ctx context.Context, dir string, ignorePatterns []string) (chan string, error) {
+	return retry(ctx, dir, ignorePatterns, watch)
 }
 
 func watch(ctx context.Context, dir string) (chan string, error) {
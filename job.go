@@ -7,4 +7,7 @@ import (
 type iJob interface {
 	Run(context.Context) error
 	String() string
+	// Paths returns the relative paths of files changed by the job.
+	// Jobs changing the same path or paths inside each other are run in the order they are created.
+	Paths() []string
 }
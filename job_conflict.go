@@ -0,0 +1,232 @@
+package putiosync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/cenkalti/log"
+)
+
+type overwriteLocalJob struct {
+	localFile  iLocalFile
+	remoteFile iRemoteFile
+	state      *stateType
+}
+
+func (j *overwriteLocalJob) String() string {
+	return fmt.Sprintf("Resolving conflict, overwriting local file %q", j.remoteFile.RelPath())
+}
+
+func (j *overwriteLocalJob) Paths() []string {
+	return []string{j.remoteFile.RelPath()}
+}
+
+func (j *overwriteLocalJob) Run(ctx context.Context) error {
+	dirConflict := j.localFile.Info().IsDir() != j.remoteFile.Info().IsDir()
+	if dirConflict {
+		// Contents of the folder are synced in next cycle.
+		defer triggerSync()
+	}
+	if j.state != nil {
+		err := j.state.Delete()
+		if err != nil {
+			return err
+		}
+	}
+	if j.remoteFile.Info().IsDir() {
+		err := j.trashLocal()
+		if err != nil {
+			return err
+		}
+		job := &createLocalFolderJob{relpath: j.remoteFile.RelPath(), remoteID: j.remoteFile.PutioFile().ID}
+		err = job.Run(ctx)
+		if err != nil {
+			// Put the local file back, so it is not left only in trash.
+			if rerr := restoreFromTrash(j.localFile.RelPath()); rerr != nil {
+				log.Errorf("Cannot restore %q from trash: %s", j.localFile.RelPath(), rerr)
+			}
+		}
+		return err
+	}
+	// Local copy is replaced only after the remote file is downloaded successfully.
+	job := &downloadJob{remoteFile: j.remoteFile, beforeReplace: j.trashLocal}
+	return job.Run(ctx)
+}
+
+// trashLocal keeps the local copy in trash, so it is not lost if the conflict is resolved wrongly.
+func (j *overwriteLocalJob) trashLocal() error {
+	err := trashBin.Move(j.localFile.RelPath(), time.Now())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// restoreFromTrash moves the last trashed item of relpath back to its place.
+func restoreFromTrash(relpath string) error {
+	items, err := trashBin.List()
+	if err != nil {
+		return err
+	}
+	for i := len(items) - 1; i >= 0; i-- {
+		if items[i].OrigPath == relpath {
+			return trashBin.Restore(items[i].Path, "")
+		}
+	}
+	return os.ErrNotExist
+}
+
+type overwriteRemoteJob struct {
+	localFile  iLocalFile
+	remoteFile iRemoteFile
+	state      *stateType
+}
+
+func (j *overwriteRemoteJob) String() string {
+	return fmt.Sprintf("Resolving conflict, overwriting remote file %q", j.localFile.RelPath())
+}
+
+func (j *overwriteRemoteJob) Paths() []string {
+	return []string{j.localFile.RelPath()}
+}
+
+func (j *overwriteRemoteJob) Run(ctx context.Context) error {
+	dirConflict := j.localFile.Info().IsDir() != j.remoteFile.Info().IsDir()
+	if dirConflict {
+		deleteCtx, cancel := context.WithTimeout(ctx, defaultTimeout)
+		err := client.Files.Delete(deleteCtx, j.remoteFile.PutioFile().ID)
+		cancel()
+		if err != nil {
+			return err
+		}
+		dirCache.Remove(j.remoteFile.RelPath())
+		// Contents of the folder are synced in next cycle.
+		defer triggerSync()
+	}
+	if j.state != nil {
+		err := j.state.Delete()
+		if err != nil {
+			return err
+		}
+	}
+	if j.localFile.Info().IsDir() {
+		job := &createRemoteFolderJob{relpath: j.localFile.RelPath()}
+		return job.Run(ctx)
+	}
+	job := &uploadJob{localFile: j.localFile}
+	return job.Run(ctx)
+}
+
+type keepBothJob struct {
+	localFile  iLocalFile
+	remoteFile iRemoteFile
+	state      *stateType
+	// Path of the renamed copy.
+	toRelpath string
+	// Rename the remote file instead of the local file.
+	renameRemote bool
+}
+
+func (j *keepBothJob) String() string {
+	if j.renameRemote {
+		return fmt.Sprintf("Resolving conflict, moving remote file from %q to %q", j.remoteFile.RelPath(), j.toRelpath)
+	}
+	return fmt.Sprintf("Resolving conflict, moving local file from %q to %q", j.localFile.RelPath(), j.toRelpath)
+}
+
+func (j *keepBothJob) Paths() []string {
+	return []string{j.localFile.RelPath(), j.toRelpath}
+}
+
+func (j *keepBothJob) Run(ctx context.Context) error {
+	if j.renameRemote {
+		return j.runRemote(ctx)
+	}
+	return j.runLocal(ctx)
+}
+
+func (j *keepBothJob) runLocal(ctx context.Context) error {
+	newPath := filepath.Join(localPath, filepath.FromSlash(j.toRelpath))
+	_, err := os.Lstat(newPath)
+	if err == nil {
+		return errors.New("file already exists at conflict target")
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	err = os.Rename(j.localFile.FullPath(), newPath)
+	if err != nil {
+		return err
+	}
+	// Renamed copy is synced in next cycle.
+	defer triggerSync()
+	if j.state != nil {
+		err = j.state.Delete()
+		if err != nil {
+			return err
+		}
+	}
+	if j.remoteFile.Info().IsDir() {
+		job := &createLocalFolderJob{relpath: j.remoteFile.RelPath(), remoteID: j.remoteFile.PutioFile().ID}
+		return job.Run(ctx)
+	}
+	job := &downloadJob{remoteFile: j.remoteFile}
+	return job.Run(ctx)
+}
+
+func (j *keepBothJob) runRemote(ctx context.Context) error {
+	dir, name := path.Split(j.toRelpath)
+	parentID, err := dirCache.Mkdirp(ctx, dir)
+	if err != nil {
+		return err
+	}
+	err = moveRemoteFile(ctx, parentID, j.remoteFile.PutioFile().ID, name)
+	if err != nil {
+		return err
+	}
+	dirCache.Remove(j.remoteFile.RelPath())
+	// Renamed copy is synced in next cycle.
+	defer triggerSync()
+	if j.state != nil {
+		err = j.state.Delete()
+		if err != nil {
+			return err
+		}
+	}
+	if j.localFile.Info().IsDir() {
+		job := &createRemoteFolderJob{relpath: j.localFile.RelPath()}
+		return job.Run(ctx)
+	}
+	job := &uploadJob{localFile: j.localFile}
+	return job.Run(ctx)
+}
+
+// renameDuplicateJob renames a remote file that has the same name with another file in the same folder.
+type renameDuplicateJob struct {
+	remoteFile iRemoteFile
+	toRelpath  string
+}
+
+func (j *renameDuplicateJob) String() string {
+	return fmt.Sprintf("Resolving name collision, moving remote file from %q to %q", j.remoteFile.RelPath(), j.toRelpath)
+}
+
+func (j *renameDuplicateJob) Paths() []string {
+	return []string{j.remoteFile.RelPath(), j.toRelpath}
+}
+
+func (j *renameDuplicateJob) Run(ctx context.Context) error {
+	pf := j.remoteFile.PutioFile()
+	err := moveRemoteFile(ctx, pf.ParentID, pf.ID, path.Base(j.toRelpath))
+	if err != nil {
+		return err
+	}
+	// Renamed copy is synced in next cycle.
+	triggerSync()
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 )
 
 type deleteLocalFileJob struct {
@@ -15,9 +16,14 @@ func (j *deleteLocalFileJob) String() string {
 	return fmt.Sprintf("Deleting local file %q", j.state.relpath)
 }
 
+func (j *deleteLocalFileJob) Paths() []string {
+	return []string{j.state.relpath}
+}
+
 func (j *deleteLocalFileJob) Run(ctx context.Context) error {
-	err := os.RemoveAll(j.localFile.FullPath())
-	if err != nil {
+	// Keep the file in trash, in case it is deleted from remote by mistake.
+	err := trashBin.Move(j.localFile.RelPath(), time.Now())
+	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
 	return j.state.Delete()
@@ -32,6 +38,10 @@ func (j *deleteRemoteFileJob) String() string {
 	return fmt.Sprintf("Deleting remote file %q", j.state.relpath)
 }
 
+func (j *deleteRemoteFileJob) Paths() []string {
+	return []string{j.state.relpath}
+}
+
 func (j *deleteRemoteFileJob) Run(ctx context.Context) error {
 	err := client.Files.Delete(ctx, j.remoteFile.PutioFile().ID)
 	if err != nil {
@@ -17,12 +17,18 @@ import (
 type downloadJob struct {
 	remoteFile iRemoteFile
 	state      *stateType
+	// Called after the downloaded data is verified, before it is moved to its place in synced dir.
+	beforeReplace func() error
 }
 
 func (d *downloadJob) String() string {
 	return fmt.Sprintf("Downloading %q", d.remoteFile.RelPath())
 }
 
+func (d *downloadJob) Paths() []string {
+	return []string{d.remoteFile.RelPath()}
+}
+
 func (d *downloadJob) tryResume() io.WriteCloser {
 	if d.state == nil {
 		return nil
@@ -88,6 +94,13 @@ func (d *downloadJob) Run(ctx context.Context) error {
 		}
 	}
 
+	// Checksum of the downloaded data, including the data downloaded before resuming.
+	tempPath := filepath.Join(tempDirPath, d.state.DownloadTempName)
+	h, err := seedCRC32(tempPath, d.state.Offset)
+	if err != nil {
+		return err
+	}
+
 	remaining := d.state.Size - d.state.Offset
 	if remaining > 0 { // nolint: nestif
 		ctx, cancel := context.WithCancel(fileWatcher.Context())
@@ -105,8 +118,10 @@ func (d *downloadJob) Run(ctx context.Context) error {
 		tr := io.TeeReader(rc, trw)
 
 		pr := progress.New(tr, d.state.Offset, d.state.Size, d.String())
+		pr.Tee(h)
 		pr.Start()
-		n, copyErr := io.CopyN(wc, pr, remaining)
+		reportProgress(ctx, pr)
+		n, copyErr := io.CopyN(wc, countBytes(downloadLimiter.Reader(ctx, pr), &metrics.downloadedBytes), remaining)
 		pr.Stop()
 
 		err = wc.Close()
@@ -131,13 +146,34 @@ func (d *downloadJob) Run(ctx context.Context) error {
 		}
 	}
 
-	oldPath := filepath.Join(tempDirPath, d.state.DownloadTempName)
+	sum := fmt.Sprintf("%08x", h.Sum32())
+	if crc32Differs(sum, d.state.CRC32) {
+		// Discard downloaded data and start over in next sync.
+		ierr := &integrityError{relpath: d.state.relpath, expected: d.state.CRC32, actual: sum}
+		err = os.Remove(tempPath)
+		if err != nil {
+			return err
+		}
+		err = d.state.Delete()
+		if err != nil {
+			return err
+		}
+		return ierr
+	}
+
+	if d.beforeReplace != nil {
+		err = d.beforeReplace()
+		if err != nil {
+			return err
+		}
+	}
+
 	newPath := filepath.Join(localPath, filepath.FromSlash(d.state.relpath))
-	err := os.MkdirAll(filepath.Dir(newPath), 0777)
+	err = os.MkdirAll(filepath.Dir(newPath), 0777)
 	if err != nil {
 		return err
 	}
-	err = os.Rename(oldPath, newPath)
+	err = os.Rename(tempPath, newPath)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,136 @@
+package putiosync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/log"
+	"github.com/putdotio/putio-sync/v2/internal/inode"
+)
+
+type excludeFolderJob struct {
+	relpath string
+	isDir   bool
+	remove  bool
+	states  []stateType
+}
+
+func (j *excludeFolderJob) String() string {
+	kind := "file"
+	if j.isDir {
+		kind = "folder"
+	}
+	if j.remove {
+		return fmt.Sprintf("Removing excluded %s %q", kind, j.relpath)
+	}
+	return fmt.Sprintf("Keeping excluded %s %q", kind, j.relpath)
+}
+
+func (j *excludeFolderJob) Paths() []string {
+	return []string{j.relpath}
+}
+
+// removedPaths returns the paths of synced files that are moved to trash if they are not changed.
+func (j *excludeFolderJob) removedPaths() []string {
+	if !j.remove {
+		return nil
+	}
+	var paths []string
+	for _, s := range j.states {
+		if !s.IsDir {
+			paths = append(paths, s.relpath)
+		}
+	}
+	return paths
+}
+
+func (j *excludeFolderJob) Run(ctx context.Context) error {
+	if j.remove {
+		err := j.removeSynced(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	for _, s := range j.states {
+		err := s.Delete()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeSynced moves the files that are same as their last synced versions into trash.
+// Files that are changed after sync or never synced are kept, so local data that is not uploaded is not lost.
+// Folders are removed if they become empty.
+func (j *excludeFolderJob) removeSynced(ctx context.Context) error {
+	now := time.Now()
+	var dirs []string
+	for _, s := range j.states {
+		if s.IsDir {
+			dirs = append(dirs, s.relpath)
+			continue
+		}
+		synced, err := isSyncedLocalFile(ctx, s)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !synced {
+			log.Warningf("Keeping %q in excluded folder because it is changed after sync", s.relpath)
+			continue
+		}
+		err = trashBin.Move(s.relpath, now)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	// Remove inner folders first.
+	sort.Slice(dirs, func(i, j int) bool { return strings.Count(dirs[i], "/") > strings.Count(dirs[j], "/") })
+	for _, relpath := range dirs {
+		name := filepath.Join(localPath, filepath.FromSlash(relpath))
+		entries, err := os.ReadDir(name)
+		if os.IsNotExist(err) || len(entries) > 0 {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = os.Remove(name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isSyncedLocalFile reports whether the local file is same as it is when the state is saved.
+func isSyncedLocalFile(ctx context.Context, s stateType) (bool, error) {
+	name := filepath.Join(localPath, filepath.FromSlash(s.relpath))
+	fi, err := os.Lstat(name)
+	if err != nil {
+		return false, err
+	}
+	if s.Status != statusSynced || !fi.Mode().IsRegular() || fi.Size() != s.Size {
+		return false, nil
+	}
+	in, err := inode.Get(name, fi)
+	if err != nil {
+		return false, err
+	}
+	if in != s.LocalInode {
+		return false, nil
+	}
+	sum, err := fileCRC32(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	return !crc32Differs(sum, s.CRC32), nil
+}
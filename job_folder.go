@@ -16,6 +16,10 @@ func (j *createLocalFolderJob) String() string {
 	return "Creating local folder " + j.relpath
 }
 
+func (j *createLocalFolderJob) Paths() []string {
+	return []string{j.relpath}
+}
+
 func (j *createLocalFolderJob) Run(ctx context.Context) error {
 	err := os.MkdirAll(filepath.Join(localPath, filepath.FromSlash(j.relpath)), 0777)
 	if err != nil {
@@ -38,6 +42,10 @@ func (j *createRemoteFolderJob) String() string {
 	return fmt.Sprintf("Creating remote folder %q", j.relpath)
 }
 
+func (j *createRemoteFolderJob) Paths() []string {
+	return []string{j.relpath}
+}
+
 func (j *createRemoteFolderJob) Run(ctx context.Context) error {
 	remoteID, err := dirCache.Mkdirp(ctx, j.relpath)
 	if err != nil {
@@ -22,6 +22,10 @@ func (j *moveLocalFileJob) String() string {
 	return fmt.Sprintf("Moving local file from %q to %q", j.state.relpath, j.toRelpath)
 }
 
+func (j *moveLocalFileJob) Paths() []string {
+	return []string{j.state.relpath, j.toRelpath}
+}
+
 func (j *moveLocalFileJob) Run(ctx context.Context) error {
 	oldPath := j.localFile.FullPath()
 	newPath := filepath.Join(localPath, filepath.FromSlash(j.toRelpath))
@@ -64,6 +68,10 @@ func (j *moveRemoteFileJob) String() string {
 	return fmt.Sprintf("Moving remote file from %q to %q", j.state.relpath, j.toRelpath)
 }
 
+func (j *moveRemoteFileJob) Paths() []string {
+	return []string{j.state.relpath, j.toRelpath}
+}
+
 func (j *moveRemoteFileJob) Run(ctx context.Context) error {
 	dir, name := path.Split(j.toRelpath)
 	parentID, err := dirCache.Mkdirp(ctx, dir)
@@ -18,6 +18,10 @@ func (j *deleteStateJob) String() string {
 	return fmt.Sprintf("Deleting state %q", j.state.relpath)
 }
 
+func (j *deleteStateJob) Paths() []string {
+	return []string{j.state.relpath}
+}
+
 func (j *deleteStateJob) Run(ctx context.Context) error {
 	if j.state.DownloadTempName != "" {
 		err := os.Remove(filepath.Join(tempDirPath, j.state.DownloadTempName))
@@ -43,6 +47,10 @@ func (j *writeFileStateJob) String() string {
 	return fmt.Sprintf("Saving file state %q", j.localFile.RelPath())
 }
 
+func (j *writeFileStateJob) Paths() []string {
+	return []string{j.localFile.RelPath()}
+}
+
 func (j *writeFileStateJob) Run(ctx context.Context) error {
 	in, err := inode.Get(j.localFile.FullPath(), j.localFile.Info())
 	if err != nil {
@@ -68,6 +76,10 @@ func (j *writeDirStateJob) String() string {
 	return fmt.Sprintf("Saving folder state %q", j.relpath)
 }
 
+func (j *writeDirStateJob) Paths() []string {
+	return []string{j.relpath}
+}
+
 func (j *writeDirStateJob) Run(ctx context.Context) error {
 	s := stateType{
 		Status:   statusSynced,
@@ -22,6 +22,10 @@ func (d *uploadJob) String() string {
 	return fmt.Sprintf("Uploading %q", d.localFile.RelPath())
 }
 
+func (d *uploadJob) Paths() []string {
+	return []string{d.localFile.RelPath()}
+}
+
 func (d *uploadJob) tryResume(ctx context.Context) bool {
 	if d.state == nil {
 		return false
@@ -86,13 +90,20 @@ func (d *uploadJob) Run(ctx context.Context) error {
 		return err
 	}
 	defer f.Close()
+	// Checksum of the uploaded data, including the data uploaded before resuming.
+	h, err := seedCRC32(d.localFile.FullPath(), d.state.Offset)
+	if err != nil {
+		return err
+	}
 	_, err = f.Seek(d.state.Offset, io.SeekStart)
 	if err != nil {
 		return err
 	}
 	pr := progress.New(f, d.state.Offset, d.state.Size, d.String())
+	pr.Tee(h)
 	pr.Start()
-	fileID, crc32, err := client.Upload.SendFile(modwatch.Context(), pr, d.state.UploadURL, d.state.Offset)
+	reportProgress(ctx, pr)
+	fileID, crc32, err := client.Upload.SendFile(modwatch.Context(), countBytes(uploadLimiter.Reader(modwatch.Context(), pr), &metrics.uploadedBytes), d.state.UploadURL, d.state.Offset)
 	pr.Stop()
 	modified := modwatch.Stop()
 	if modified {
@@ -102,12 +113,22 @@ func (d *uploadJob) Run(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	sum := fmt.Sprintf("%08x", h.Sum32())
+	if crc32Differs(sum, crc32) {
+		// Delete the corrupted file and start over in next sync.
+		ierr := &integrityError{relpath: d.state.relpath, expected: sum, actual: crc32}
+		err = client.Files.Delete(ctx, fileID)
+		if err != nil {
+			return err
+		}
+		err = d.state.Delete()
+		if err != nil {
+			return err
+		}
+		return ierr
+	}
 	d.state.Status = statusSynced
 	d.state.RemoteID = fileID
 	d.state.CRC32 = crc32
-	err = d.state.Write()
-	if err != nil {
-		return err
-	}
-	return nil
+	return d.state.Write()
 }
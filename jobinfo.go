@@ -0,0 +1,197 @@
+package putiosync
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/log"
+	"github.com/putdotio/putio-sync/v2/internal/api"
+	"github.com/putdotio/putio-sync/v2/internal/progress"
+)
+
+const (
+	// Number of finished jobs kept for showing in the control API.
+	maxFinishedJobs = 100
+	// Number of queued jobs shown in the control API. Only totals are shown for the rest, see queuedTotals.
+	maxListedQueuedJobs = 100
+)
+
+const (
+	jobQueued  = "queued"
+	jobRunning = "running"
+	jobDone    = "done"
+	jobFailed  = "failed"
+	jobSkipped = "skipped"
+)
+
+// jobInfo is the state of a job that is shown in the control API.
+type jobInfo struct {
+	api.Job
+
+	progress *progress.Progress
+}
+
+type jobInfoKey struct{}
+
+// withJobInfo returns a context that jobs can report their progress with.
+func withJobInfo(ctx context.Context, info *jobInfo) context.Context {
+	return context.WithValue(ctx, jobInfoKey{}, info)
+}
+
+// reportProgress sets the progress of the job that is run with ctx.
+func reportProgress(ctx context.Context, pr *progress.Progress) {
+	info, ok := ctx.Value(jobInfoKey{}).(*jobInfo)
+	if !ok {
+		return
+	}
+	jobTracker.m.Lock()
+	info.progress = pr
+	jobTracker.m.Unlock()
+}
+
+// updateProgress copies the numbers from the progress of the job. Must be called with lock held.
+func (info *jobInfo) updateProgress() {
+	if info.progress == nil {
+		return
+	}
+	info.BytesDone, info.BytesTotal, info.Speed = info.progress.Stats()
+	info.ETA = 0
+	if info.Speed > 0 && info.BytesTotal > info.BytesDone {
+		info.ETA = (info.BytesTotal - info.BytesDone) / info.Speed
+	}
+}
+
+// jobTracker contains the jobs of current sync and recently finished jobs.
+var jobTracker = struct {
+	m        sync.Mutex
+	nextID   int64
+	active   []*jobInfo
+	finished []*jobInfo
+}{}
+
+// trackJobs adds the jobs to the queue. Returned infos are in the same order with jobs.
+func trackJobs(jobs []iJob) []*jobInfo {
+	failures, err := readFailures()
+	if err != nil {
+		log.Errorln("cannot read failures:", err.Error())
+	}
+	jobTracker.m.Lock()
+	defer jobTracker.m.Unlock()
+	now := time.Now()
+	infos := make([]*jobInfo, len(jobs))
+	for i, job := range jobs {
+		e := newPlanEntry(job, nil)
+		jobTracker.nextID++
+		f := failures[e.Path]
+		infos[i] = &jobInfo{Job: api.Job{
+			ID:          jobTracker.nextID,
+			Folder:      folder.Name,
+			Type:        e.Type,
+			Path:        e.Path,
+			To:          e.To,
+			Description: job.String(),
+			State:       jobQueued,
+			QueuedAt:    now,
+			BytesTotal:  e.Size,
+			Attempt:     f.Attempts + 1,
+			LastError:   f.LastError,
+		}}
+		jobTracker.active = append(jobTracker.active, infos[i])
+	}
+	return infos
+}
+
+func jobStarted(info *jobInfo) {
+	jobTracker.m.Lock()
+	info.State = jobRunning
+	info.StartedAt = time.Now()
+	jobTracker.m.Unlock()
+}
+
+// jobFinished moves the job to the finished list. State is set from the error unless it is given.
+func jobFinished(info *jobInfo, state string, err error) {
+	jobTracker.m.Lock()
+	defer jobTracker.m.Unlock()
+	if state == "" {
+		state = jobDone
+		if err != nil {
+			state = jobFailed
+		}
+	}
+	info.State = state
+	if err != nil {
+		info.Error = err.Error()
+	}
+	info.FinishedAt = time.Now()
+	info.updateProgress()
+	info.progress = nil
+	info.Speed, info.ETA = 0, 0
+	countJob(info.Type, state)
+	for i, a := range jobTracker.active {
+		if a == info {
+			jobTracker.active = append(jobTracker.active[:i], jobTracker.active[i+1:]...)
+			break
+		}
+	}
+	jobTracker.finished = append(jobTracker.finished, info)
+	if len(jobTracker.finished) > maxFinishedJobs {
+		jobTracker.finished = jobTracker.finished[len(jobTracker.finished)-maxFinishedJobs:]
+	}
+}
+
+// untrackJobs removes the jobs that are not started, e.g. when sync is stopped.
+func untrackJobs(infos []*jobInfo) {
+	jobTracker.m.Lock()
+	defer jobTracker.m.Unlock()
+	remove := make(map[*jobInfo]struct{}, len(infos))
+	for _, info := range infos {
+		remove[info] = struct{}{}
+	}
+	active := jobTracker.active[:0]
+	for _, a := range jobTracker.active {
+		if _, ok := remove[a]; !ok {
+			active = append(active, a)
+		}
+	}
+	jobTracker.active = active
+}
+
+// queuedTotals returns the totals of all queued jobs, including the ones not returned by listJobs.
+func queuedTotals() api.QueueTotals {
+	jobTracker.m.Lock()
+	defer jobTracker.m.Unlock()
+	var t api.QueueTotals
+	for _, info := range jobTracker.active {
+		if info.State == jobQueued {
+			t.Jobs++
+			t.Bytes += info.BytesTotal
+		}
+	}
+	return t
+}
+
+// listJobs returns copies of running, queued and recently finished jobs, in that order.
+// Only the first maxListedQueuedJobs of queued jobs are returned, because there can be many of them on first sync.
+func listJobs() []api.Job {
+	jobTracker.m.Lock()
+	defer jobTracker.m.Unlock()
+	l := make([]api.Job, 0, maxListedQueuedJobs+len(jobTracker.finished))
+	for _, info := range jobTracker.active {
+		if info.State == jobRunning {
+			info.updateProgress()
+			l = append(l, info.Job)
+		}
+	}
+	var queued int
+	for _, info := range jobTracker.active {
+		if info.State == jobQueued && queued < maxListedQueuedJobs {
+			queued++
+			l = append(l, info.Job)
+		}
+	}
+	for i := len(jobTracker.finished) - 1; i >= 0; i-- {
+		l = append(l, jobTracker.finished[i].Job)
+	}
+	return l
+}
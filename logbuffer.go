@@ -0,0 +1,67 @@
+package putiosync
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/cenkalti/log"
+	"github.com/putdotio/putio-sync/v2/internal/api"
+)
+
+// Number of log messages kept for showing in the control API.
+const logBufferSize = 1000
+
+// logBuffer is a log handler that keeps the last messages in memory and passes them to the next handler.
+type logBuffer struct {
+	next    log.Handler
+	m       sync.Mutex
+	entries []api.LogEntry
+	seq     int64
+	// Closed when a new message is added.
+	changed chan struct{}
+}
+
+// logs is set when Sync is started.
+var logs *logBuffer
+
+func newLogBuffer(next log.Handler) *logBuffer {
+	return &logBuffer{
+		next:    next,
+		changed: make(chan struct{}),
+	}
+}
+
+func (b *logBuffer) SetFormatter(f log.Formatter) { b.next.SetFormatter(f) }
+func (b *logBuffer) SetLevel(l log.Level)         { b.next.SetLevel(l) }
+func (b *logBuffer) Close() error                 { return b.next.Close() }
+
+func (b *logBuffer) Handle(rec *log.Record) {
+	b.next.Handle(rec)
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.seq++
+	b.entries = append(b.entries, api.LogEntry{
+		Seq:     b.seq,
+		Time:    rec.Time,
+		Level:   rec.Level.String(),
+		Message: strings.TrimRight(rec.Message, "\n"),
+	})
+	if len(b.entries) > logBufferSize {
+		b.entries = b.entries[len(b.entries)-logBufferSize:]
+	}
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// since returns the messages after seq and a channel that is closed when a new message is added.
+func (b *logBuffer) since(seq int64) ([]api.LogEntry, <-chan struct{}) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	var l []api.LogEntry
+	for _, e := range b.entries {
+		if e.Seq > seq {
+			l = append(l, e)
+		}
+	}
+	return l, b.changed
+}
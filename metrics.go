@@ -0,0 +1,205 @@
+package putiosync
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+)
+
+// Upper bounds of API latency histogram buckets in seconds.
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type histogram struct {
+	counts []int64
+	sum    float64
+	count  int64
+}
+
+func (h *histogram) observe(v float64) {
+	if h.counts == nil {
+		h.counts = make([]int64, len(latencyBuckets))
+	}
+	for i, b := range latencyBuckets {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// jobOutcome is the label set of jobs counter.
+type jobOutcome struct {
+	typ     string
+	outcome string
+}
+
+// metrics are exported in Prometheus text format at /metrics endpoint of the server.
+var metrics = struct {
+	// Updated atomically.
+	uploadedBytes   int64
+	downloadedBytes int64
+	watchedDirs     int64
+
+	m                  sync.Mutex
+	jobs               map[jobOutcome]int64
+	cycleDuration      time.Duration
+	lastSuccess        time.Time
+	localWalkDuration  time.Duration
+	remoteWalkDuration time.Duration
+	// Keyed by endpoint.
+	apiLatency map[string]*histogram
+}{
+	jobs:       make(map[jobOutcome]int64),
+	apiLatency: make(map[string]*histogram),
+}
+
+func countJob(typ, outcome string) {
+	metrics.m.Lock()
+	metrics.jobs[jobOutcome{typ, outcome}]++
+	metrics.m.Unlock()
+}
+
+func observeCycle(d time.Duration, success bool) {
+	metrics.m.Lock()
+	metrics.cycleDuration = d
+	if success {
+		metrics.lastSuccess = time.Now()
+	}
+	metrics.m.Unlock()
+}
+
+func observeWalk(local, remote time.Duration) {
+	metrics.m.Lock()
+	metrics.localWalkDuration = local
+	metrics.remoteWalkDuration = remote
+	metrics.m.Unlock()
+}
+
+// countingReader adds the number of bytes read to a counter in metrics.
+type countingReader struct {
+	r       io.Reader
+	counter *int64
+}
+
+func countBytes(r io.Reader, counter *int64) io.Reader {
+	return &countingReader{r: r, counter: counter}
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	atomic.AddInt64(r.counter, int64(n))
+	return n, err
+}
+
+// apiTransport records the latency of put.io API requests.
+type apiTransport struct {
+	next http.RoundTripper
+}
+
+func (t *apiTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != "api.put.io" {
+		return t.next.RoundTrip(req)
+	}
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	endpoint := req.Method + " " + apiEndpoint(req.URL.Path)
+	metrics.m.Lock()
+	h, ok := metrics.apiLatency[endpoint]
+	if !ok {
+		h = &histogram{}
+		metrics.apiLatency[endpoint] = h
+	}
+	h.observe(time.Since(start).Seconds())
+	metrics.m.Unlock()
+	return resp, err
+}
+
+// apiEndpoint replaces IDs in the path, so requests for different files are counted together.
+func apiEndpoint(p string) string {
+	parts := strings.Split(p, "/")
+	for i, s := range parts {
+		if s != "" && strings.IndexFunc(s, func(r rune) bool { return !unicode.IsDigit(r) }) == -1 {
+			parts[i] = ":id"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// writeMetrics writes all metrics in Prometheus text format.
+func writeMetrics(w io.Writer) {
+	var pending int
+	jobTracker.m.Lock()
+	pending = len(jobTracker.active)
+	jobTracker.m.Unlock()
+	conflicts, _ := listConflicts()
+	connected := 0
+	if notifier.Connected() {
+		connected = 1
+	}
+
+	metric := func(name, typ, help string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+	metric("putio_sync_uploaded_bytes_total", "counter", "Number of bytes uploaded.")
+	fmt.Fprintf(w, "putio_sync_uploaded_bytes_total %d\n", atomic.LoadInt64(&metrics.uploadedBytes))
+	metric("putio_sync_downloaded_bytes_total", "counter", "Number of bytes downloaded.")
+	fmt.Fprintf(w, "putio_sync_downloaded_bytes_total %d\n", atomic.LoadInt64(&metrics.downloadedBytes))
+	metric("putio_sync_websocket_connected", "gauge", "Whether the connection for remote change notifications is up.")
+	fmt.Fprintf(w, "putio_sync_websocket_connected %d\n", connected)
+	metric("putio_sync_watched_dirs", "gauge", "Number of local dirs watched for changes.")
+	fmt.Fprintf(w, "putio_sync_watched_dirs %d\n", atomic.LoadInt64(&metrics.watchedDirs))
+	metric("putio_sync_pending_jobs", "gauge", "Number of queued and running jobs.")
+	fmt.Fprintf(w, "putio_sync_pending_jobs %d\n", pending)
+	metric("putio_sync_conflicts", "gauge", "Number of recorded conflicts.")
+	fmt.Fprintf(w, "putio_sync_conflicts %d\n", len(conflicts))
+
+	metrics.m.Lock()
+	defer metrics.m.Unlock()
+	metric("putio_sync_jobs_total", "counter", "Number of finished jobs by type and outcome.")
+	outcomes := make([]jobOutcome, 0, len(metrics.jobs))
+	for k := range metrics.jobs {
+		outcomes = append(outcomes, k)
+	}
+	sort.Slice(outcomes, func(i, j int) bool {
+		if outcomes[i].typ != outcomes[j].typ {
+			return outcomes[i].typ < outcomes[j].typ
+		}
+		return outcomes[i].outcome < outcomes[j].outcome
+	})
+	for _, k := range outcomes {
+		fmt.Fprintf(w, "putio_sync_jobs_total{type=%q,outcome=%q} %d\n", k.typ, k.outcome, metrics.jobs[k])
+	}
+	metric("putio_sync_cycle_duration_seconds", "gauge", "Duration of the last sync cycle.")
+	fmt.Fprintf(w, "putio_sync_cycle_duration_seconds %g\n", metrics.cycleDuration.Seconds())
+	metric("putio_sync_last_success_timestamp_seconds", "gauge", "Unix time of the last successful sync cycle.")
+	var lastSuccess int64
+	if !metrics.lastSuccess.IsZero() {
+		lastSuccess = metrics.lastSuccess.Unix()
+	}
+	fmt.Fprintf(w, "putio_sync_last_success_timestamp_seconds %d\n", lastSuccess)
+	metric("putio_sync_walk_duration_seconds", "gauge", "Duration of the last walk of local and remote folders.")
+	fmt.Fprintf(w, "putio_sync_walk_duration_seconds{side=\"local\"} %g\n", metrics.localWalkDuration.Seconds())
+	fmt.Fprintf(w, "putio_sync_walk_duration_seconds{side=\"remote\"} %g\n", metrics.remoteWalkDuration.Seconds())
+	metric("putio_sync_api_request_duration_seconds", "histogram", "Latency of put.io API requests by endpoint.")
+	endpoints := make([]string, 0, len(metrics.apiLatency))
+	for k := range metrics.apiLatency {
+		endpoints = append(endpoints, k)
+	}
+	sort.Strings(endpoints)
+	for _, e := range endpoints {
+		h := metrics.apiLatency[e]
+		for i, b := range latencyBuckets {
+			fmt.Fprintf(w, "putio_sync_api_request_duration_seconds_bucket{endpoint=%q,le=\"%g\"} %d\n", e, b, h.counts[i])
+		}
+		fmt.Fprintf(w, "putio_sync_api_request_duration_seconds_bucket{endpoint=%q,le=\"+Inf\"} %d\n", e, h.count)
+		fmt.Fprintf(w, "putio_sync_api_request_duration_seconds_sum{endpoint=%q} %g\n", e, h.sum)
+		fmt.Fprintf(w, "putio_sync_api_request_duration_seconds_count{endpoint=%q} %d\n", e, h.count)
+	}
+}
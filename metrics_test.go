@@ -0,0 +1,17 @@
+package putiosync
+
+import "testing"
+
+func TestAPIEndpoint(t *testing.T) {
+	cases := map[string]string{
+		"/v2/files/list":        "/v2/files/list",
+		"/v2/files/123":         "/v2/files/:id",
+		"/v2/files/123/url":     "/v2/files/:id/url",
+		"/v2/files/123abc/list": "/v2/files/123abc/list",
+	}
+	for p, expected := range cases {
+		if e := apiEndpoint(p); e != expected {
+			t.Errorf("%s: expected %s, got %s", p, expected, e)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package putiosync
+
+// syncMode determines the directions that changes are synced between local and remote sides of a sync pair.
+type syncMode string
+
+const (
+	// Changes on both sides are synced to the other side.
+	modeTwoWay syncMode = "two-way"
+	// Remote changes are synced to local side. Local changes are never uploaded and remote files are never deleted.
+	modeDownloadOnly syncMode = "download-only"
+	// Local changes are synced to remote side. Local files are never changed.
+	modeUploadOnly syncMode = "upload-only"
+	// Local side is made an exact copy of remote side. Local changes are reverted.
+	modeMirrorRemote syncMode = "mirror-remote"
+	// Remote side is made an exact copy of local side. Remote changes are reverted.
+	modeMirrorLocal syncMode = "mirror-local"
+)
+
+func (m syncMode) valid() bool {
+	switch m {
+	case modeTwoWay, modeDownloadOnly, modeUploadOnly, modeMirrorRemote, modeMirrorLocal:
+		return true
+	default:
+		return false
+	}
+}
+
+// uploads reports whether local changes are copied to remote side.
+func (m syncMode) uploads() bool {
+	return m == modeTwoWay || m == modeUploadOnly || m == modeMirrorLocal
+}
+
+// downloads reports whether remote changes are copied to local side.
+func (m syncMode) downloads() bool {
+	return m == modeTwoWay || m == modeDownloadOnly || m == modeMirrorRemote
+}
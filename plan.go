@@ -0,0 +1,301 @@
+package putiosync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cenkalti/log"
+	"github.com/putdotio/putio-sync/v2/internal/auth"
+	"github.com/putdotio/putio-sync/v2/internal/dircache"
+	"go.etcd.io/bbolt"
+)
+
+// Plan is the list of changes that a sync would make.
+type Plan struct {
+	Folders []FolderPlan `json:"folders"`
+	Summary PlanSummary  `json:"summary"`
+}
+
+// FolderPlan is the list of changes for a sync pair.
+type FolderPlan struct {
+	Folder  string      `json:"folder"`
+	Entries []PlanEntry `json:"entries"`
+	// Set if deletions would be blocked for exceeding the limits.
+	Blocked string `json:"blocked,omitempty"`
+	// Set if the folder cannot be synced.
+	Error string `json:"error,omitempty"`
+}
+
+// PlanEntry is a single job of a sync.
+type PlanEntry struct {
+	Type string `json:"type"`
+	Path string `json:"path"`
+	// Source and target paths of moves.
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	// Number of bytes to be transferred.
+	Size   int64  `json:"size"`
+	Reason string `json:"reason"`
+}
+
+// PlanSummary contains the totals of all folders in a plan.
+type PlanSummary struct {
+	Counts     map[string]int `json:"counts"`
+	TotalBytes int64          `json:"totalBytes"`
+}
+
+func (s *PlanSummary) add(entries []PlanEntry) {
+	for _, e := range entries {
+		s.Counts[e.Type]++
+		s.TotalBytes += e.Size
+	}
+}
+
+// MakePlan walks the local and remote folders and returns what a sync would do without changing anything.
+// The database is copied into a temporary file, so nothing is changed in it.
+// The database is locked while putio-sync is running, so a plan cannot be made until it is stopped.
+func MakePlan(ctx context.Context, config Config) (*Plan, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	if config.Debug {
+		log.SetLevel(log.DEBUG)
+	}
+	cfg = config
+	cfg.Folders = config.folders()
+	cfg.DryRun = true
+	var err error
+	hostname, err = os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	tmpPath, err := copyDB()
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmpPath)
+	db, err = bbolt.Open(tmpPath, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	err = migrateStates(cfg.Folders)
+	if err != nil {
+		return nil, err
+	}
+	token, client, err = auth.Authenticate(ctx, httpClient, defaultTimeout, cfg.Username, cfg.Password)
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	plan := &Plan{Summary: PlanSummary{Counts: make(map[string]int)}}
+	for _, f := range cfg.Folders {
+		folder = f
+		fp := FolderPlan{Folder: f.Name, Entries: []PlanEntry{}}
+		err = planFolder(ctx, &fp)
+		if err != nil {
+			fp.Error = err.Error()
+		}
+		plan.Summary.add(fp.Entries)
+		plan.Folders = append(plan.Folders, fp)
+	}
+	return plan, ctx.Err()
+}
+
+func planFolder(ctx context.Context, fp *FolderPlan) error {
+	err := createPairBuckets()
+	if err != nil {
+		return err
+	}
+	err = ensureRoots(ctx)
+	if err != nil {
+		return err
+	}
+	dirCache = dircache.New(client, defaultTimeout, remoteFolderID)
+	jobs, syncFiles, tracked, err := planJobs(ctx)
+	if err != nil {
+		return err
+	}
+	if _, blockedErr := guardDeletes(jobs, tracked); blockedErr != nil {
+		fp.Blocked = blockedErr.Error()
+	}
+	for _, job := range jobs {
+		fp.Entries = append(fp.Entries, newPlanEntry(job, syncFiles))
+	}
+	return nil
+}
+
+// copyDB copies the database into a temporary file and returns its path.
+// An empty database is created if there is no database yet.
+func copyDB() (string, error) {
+	f, err := os.CreateTemp("", "putio-sync-plan-*.db")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := f.Name()
+	f.Close()
+	dbPath, err := dataFile("sync.db")
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	_, err = os.Stat(dbPath)
+	if os.IsNotExist(err) {
+		return tmpPath, os.Remove(tmpPath)
+	}
+	if err != nil {
+		return "", err
+	}
+	src, err := bbolt.Open(dbPath, 0666, &bbolt.Options{ReadOnly: true, Timeout: time.Second})
+	if errors.Is(err, bbolt.ErrTimeout) {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("database %q is locked by the running putio-sync, a plan can be made only while it is stopped", dbPath)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	defer src.Close()
+	err = src.View(func(tx *bbolt.Tx) error {
+		return tx.CopyFile(tmpPath, 0600)
+	})
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	return tmpPath, nil
+}
+
+func newPlanEntry(job iJob, syncFiles map[string]*syncFile) PlanEntry {
+	e := PlanEntry{Path: job.Paths()[0]}
+	sf := syncFiles[e.Path]
+	switch j := job.(type) {
+	case *downloadJob:
+		e.Type = "download"
+		e.Size = j.remoteFile.PutioFile().Size
+		e.Reason = changeReason(sf)
+	case *uploadJob:
+		e.Type = "upload"
+		e.Size = j.localFile.Info().Size()
+		e.Reason = changeReason(sf)
+	case *overwriteLocalJob:
+		e.Type = "overwrite-local"
+		e.Size = j.remoteFile.PutioFile().Size
+		e.Reason = conflictReason(sf)
+	case *overwriteRemoteJob:
+		e.Type = "overwrite-remote"
+		e.Size = j.localFile.Info().Size()
+		e.Reason = conflictReason(sf)
+	case *keepBothJob:
+		e.Type = "keep-both"
+		e.From, e.To = e.Path, j.toRelpath
+		if j.renameRemote {
+			e.Size = j.localFile.Info().Size()
+		} else {
+			e.Size = j.remoteFile.PutioFile().Size
+		}
+		e.Reason = conflictReason(sf)
+	case *renameDuplicateJob:
+		e.Type = "rename-duplicate"
+		e.From, e.To = e.Path, j.toRelpath
+		e.Reason = "multiple remote files with the same name"
+	case *moveLocalFileJob:
+		e.Type = "move-local"
+		e.From, e.To = e.Path, j.toRelpath
+		e.Reason = "moved on remote"
+	case *moveRemoteFileJob:
+		e.Type = "move-remote"
+		e.From, e.To = e.Path, j.toRelpath
+		e.Reason = "moved locally"
+	case *deleteLocalFileJob:
+		e.Type = "delete-local"
+		e.Reason = changeReason(sf)
+	case *deleteRemoteFileJob:
+		e.Type = "delete-remote"
+		e.Reason = changeReason(sf)
+	case *createLocalFolderJob:
+		e.Type = "create-local-folder"
+		e.Reason = changeReason(sf)
+	case *createRemoteFolderJob:
+		e.Type = "create-remote-folder"
+		e.Reason = changeReason(sf)
+	case *excludeFolderJob:
+		e.Type = "exclude-folder"
+		e.Reason = "folder is not selected"
+	case *writeFileStateJob, *writeDirStateJob:
+		e.Type = "save-state"
+		e.Reason = "same on both sides"
+	case *deleteStateJob:
+		e.Type = "delete-state"
+		e.Reason = "deleted on both sides"
+	default:
+		e.Type = fmt.Sprintf("%T", job)
+		e.Reason = job.String()
+	}
+	return e
+}
+
+// changeReason describes how the file has changed since last sync.
+func changeReason(sf *syncFile) string {
+	if sf == nil {
+		return ""
+	}
+	if sf.conflict != "" {
+		return conflictReason(sf)
+	}
+	if sf.state == nil {
+		switch {
+		case sf.local != nil && sf.remote != nil:
+			return "exists on both sides"
+		case sf.local != nil:
+			return "new local file"
+		case sf.remote != nil:
+			return "new remote file"
+		}
+		return ""
+	}
+	if sf.state.Status != statusSynced {
+		return "resuming " + string(sf.state.Status)
+	}
+	switch {
+	case sf.local == nil && sf.remote == nil:
+		return "deleted on both sides"
+	case sf.local == nil:
+		return "deleted locally"
+	case sf.remote == nil:
+		return "deleted on remote"
+	case sf.local.Info().IsDir() || sf.remote.Info().IsDir():
+		return ""
+	}
+	var reason string
+	if size := sf.local.Info().Size(); size != sf.state.Size {
+		reason = fmt.Sprintf("local size changed %d→%d", sf.state.Size, size)
+	} else if crc32Differs(sf.localCRC32, sf.state.CRC32) {
+		reason = "local content changed"
+	}
+	if size := sf.remote.PutioFile().Size; size != sf.state.Size {
+		reason = joinReason(reason, fmt.Sprintf("remote size changed %d→%d", sf.state.Size, size))
+	} else if crc32Differs(sf.remote.PutioFile().CRC32, sf.state.CRC32) {
+		reason = joinReason(reason, "remote content changed")
+	}
+	return reason
+}
+
+func conflictReason(sf *syncFile) string {
+	if sf == nil || sf.conflict == "" {
+		return ""
+	}
+	return "conflict: " + string(sf.conflict)
+}
+
+func joinReason(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + ", " + b
+}
@@ -0,0 +1,149 @@
+package putiosync
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/putdotio/go-putio"
+	"github.com/putdotio/putio-sync/v2/internal/inode"
+)
+
+func TestPlanEntry(t *testing.T) {
+	remote := func(relpath string, id, size int64) *FakeRemoteFile {
+		rf := fakeRemoteFile(relpath)
+		rf.putioFile.ID = id
+		rf.putioFile.Size = size
+		rf.putioFile.CRC32 = "0000000a"
+		return rf
+	}
+	synced := func(relpath string, lf *FakeLocalFile) *stateType {
+		s := &stateType{Status: statusSynced, RemoteID: 1, Size: 10, CRC32: "0000000a", relpath: relpath}
+		if lf != nil {
+			s.LocalInode, _ = inode.Get(lf.fullpath, lf.info)
+		}
+		return s
+	}
+	unmoved := fakeLocalFileSize(t, "old", 10)
+	moved := fakeLocalFileSize(t, "new", 10)
+	cases := []struct {
+		name      string
+		syncFiles map[string]*syncFile
+		expected  PlanEntry
+	}{
+		{
+			"upload",
+			map[string]*syncFile{"foo": {relpath: "foo", local: fakeLocalFileSize(t, "foo", 12), remote: remote("foo", 1, 10), state: synced("foo", nil)}},
+			PlanEntry{Type: "upload", Path: "foo", Size: 12, Reason: "local size changed 10→12"},
+		},
+		{
+			"download",
+			map[string]*syncFile{"foo": {relpath: "foo", remote: remote("foo", 1, 20)}},
+			PlanEntry{Type: "download", Path: "foo", Size: 20, Reason: "new remote file"},
+		},
+		{
+			"move local",
+			map[string]*syncFile{
+				"old": {relpath: "old", local: unmoved, state: synced("old", unmoved)},
+				"new": {relpath: "new", remote: remote("new", 1, 10)},
+			},
+			PlanEntry{Type: "move-local", Path: "old", From: "old", To: "new", Reason: "moved on remote"},
+		},
+		{
+			"move remote",
+			map[string]*syncFile{
+				"old": {relpath: "old", remote: remote("old", 1, 10), state: synced("old", moved)},
+				"new": {relpath: "new", local: moved},
+			},
+			PlanEntry{Type: "move-remote", Path: "old", From: "old", To: "new", Reason: "moved locally"},
+		},
+		{
+			"delete local",
+			map[string]*syncFile{"foo": {relpath: "foo", local: fakeLocalFileSize(t, "foo", 10), state: synced("foo", nil)}},
+			PlanEntry{Type: "delete-local", Path: "foo", Reason: "deleted on remote"},
+		},
+		{
+			"delete remote",
+			map[string]*syncFile{"foo": {relpath: "foo", remote: remote("foo", 2, 10), state: synced("foo", nil)}},
+			PlanEntry{Type: "delete-remote", Path: "foo", Reason: "deleted locally"},
+		},
+	}
+	for _, c := range cases {
+		jobs := reconciliation(c.syncFiles, reconOptions{mode: modeTwoWay})
+		if len(jobs) != 1 {
+			t.Errorf("%s: expected 1 job, got %d", c.name, len(jobs))
+			continue
+		}
+		e := newPlanEntry(jobs[0], c.syncFiles)
+		if e != c.expected {
+			t.Errorf("%s: expected %+v, got %+v", c.name, c.expected, e)
+		}
+	}
+}
+
+func TestPlanSummary(t *testing.T) {
+	s := PlanSummary{Counts: make(map[string]int)}
+	s.add([]PlanEntry{
+		{Type: "upload", Path: "a", Size: 10},
+		{Type: "upload", Path: "b", Size: 20},
+		{Type: "download", Path: "c", Size: 5},
+	})
+	s.add([]PlanEntry{
+		{Type: "move-local", Path: "d", From: "d", To: "e"},
+		{Type: "delete-remote", Path: "f"},
+	})
+	expected := PlanSummary{
+		Counts:     map[string]int{"upload": 2, "download": 1, "move-local": 1, "delete-remote": 1},
+		TotalBytes: 35,
+	}
+	if !reflect.DeepEqual(s, expected) {
+		t.Errorf("expected %+v, got %+v", expected, s)
+	}
+}
+
+func TestPlanMissingLocalDir(t *testing.T) {
+	openTestDB(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v2/files/5":
+			_, _ = w.Write([]byte(`{"file": {"id": 5, "name": "remote", "content_type": "application/x-directory"}}`))
+		case "/v2/files/list":
+			_, _ = w.Write([]byte(`{"files": [{"id": 6, "name": "foo", "size": 3, "parent_id": 5, "content_type": "text/plain", "crc32": "0000000a"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	client = putio.NewClient(srv.Client())
+	client.BaseURL, _ = url.Parse(srv.URL)
+	localDir := filepath.Join(t.TempDir(), "missing")
+	cfg = Config{DryRun: true}
+	folder = FolderConfig{Name: "test", LocalDir: localDir, RemoteFolderID: 5, Mode: string(modeTwoWay)}
+	defer func() {
+		cfg, folder, client = Config{}, FolderConfig{}, nil
+		delete(remoteTrees, "test")
+	}()
+
+	fp := FolderPlan{Folder: folder.Name, Entries: []PlanEntry{}}
+	err := planFolder(context.Background(), &fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []PlanEntry{{Type: "download", Path: "foo", Size: 3, Reason: "new remote file"}}
+	if !reflect.DeepEqual(fp.Entries, expected) {
+		t.Errorf("expected %+v, got %+v", expected, fp.Entries)
+	}
+	if _, err = os.Stat(localDir); !os.IsNotExist(err) {
+		t.Errorf("local dir must not be created in dry-run mode: %v", err)
+	}
+	var root remoteRoot
+	if found, _ := readMeta(metaRemoteRoot, &root); found {
+		t.Error("remote root must not be saved in dry-run mode")
+	}
+}
@@ -1,17 +1,30 @@
 package putiosync
 
 import (
+	"path"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/cenkalti/log"
 	"github.com/putdotio/putio-sync/v2/internal/inode"
 )
 
+// reconOptions contains the settings of a sync pair that affects the result of reconciliation.
+type reconOptions struct {
+	mode           syncMode
+	conflictPolicy conflictPolicy
+	// Used in names of renamed copies of conflicting files.
+	hostname string
+	now      time.Time
+	// Actions set by the user for resolving conflicts, keyed by path.
+	resolutions map[string]string
+}
+
 // Reconciliation function does not perform any operation.
 // It only takes the filesystem state as input and returns operations to be performed on those fileystems.
 // It must be testable without side effects.
-func reconciliation(syncFiles map[string]*syncFile) []iJob {
+func reconciliation(syncFiles map[string]*syncFile, opts reconOptions) []iJob {
 	var jobs []iJob
 
 	// Sort files by path for deterministic output
@@ -24,11 +37,27 @@ func reconciliation(syncFiles map[string]*syncFile) []iJob {
 	filesByRemoteID := mapRemoteFilesByID(syncFiles)
 	filesByInode := mapLocalFilesByInode(syncFiles)
 
+	// Files inside a folder that conflicts with a file are synced after the conflict is resolved.
+	conflictDirs := make(map[string]struct{})
+	for _, sf := range files {
+		if isDirConflict(sf) && mirror(sf, opts) == nil && resolveConflict(sf, opts) != nil {
+			conflictDirs[sf.relpath] = struct{}{}
+		}
+	}
+	inConflictDir := func(relpath string) bool {
+		for dir := path.Dir(relpath); dir != "."; dir = path.Dir(dir) {
+			if _, ok := conflictDirs[dir]; ok {
+				return true
+			}
+		}
+		return false
+	}
+
 	// First, sync files with known state
 	// This is required for detecting simple move operations correctly.
 	for _, sf := range files {
-		if sf.state != nil {
-			for _, job := range syncWithState(sf, filesByRemoteID, filesByInode) {
+		if sf.state != nil && !inConflictDir(sf.relpath) {
+			for _, job := range syncWithState(sf, filesByRemoteID, filesByInode, opts) {
 				if job != nil {
 					jobs = append(jobs, job)
 				}
@@ -38,10 +67,17 @@ func reconciliation(syncFiles map[string]*syncFile) []iJob {
 
 	// Then, sync first seen files
 	for _, sf := range files {
-		if sf.state == nil && !sf.skip {
-			job := syncFresh(sf)
-			if job != nil {
-				jobs = append(jobs, job)
+		if sf.state == nil && !sf.skip && !inConflictDir(sf.relpath) {
+			jobs = append(jobs, syncFresh(sf, opts)...)
+		}
+	}
+
+	// Finally, rename remote files with same names
+	for _, sf := range files {
+		if len(sf.duplicates) > 0 && sf.conflict == "" && !inConflictDir(sf.relpath) {
+			jobs = append(jobs, markConflict(sf, conflictKindNameCollision, opts)...)
+			if !sf.conflictResolved {
+				log.Warningf("Multiple remote files with same name, syncing only one of them: %q", sf.relpath)
 			}
 		}
 	}
@@ -49,52 +85,76 @@ func reconciliation(syncFiles map[string]*syncFile) []iJob {
 	return jobs
 }
 
-func syncFresh(sf *syncFile) iJob {
+func syncFresh(sf *syncFile, opts reconOptions) []iJob {
 	switch {
 	case sf.local != nil && sf.remote == nil:
-		// File present only on local side. Copy to the remote side.
-		if sf.local.Info().IsDir() {
-			return &createRemoteFolderJob{
-				relpath: sf.relpath,
-			}
-		}
-		return &uploadJob{
-			localFile: sf.local,
+		// File present only on local side.
+		switch {
+		case opts.mode == modeMirrorRemote:
+			// File does not exist in remote side. Delete it from local side.
+			return []iJob{deleteLocal(sf)}
+		case opts.mode.uploads():
+			// Copy to the remote side.
+			return []iJob{upload(sf)}
+		default:
+			return nil
 		}
 	case sf.local == nil && sf.remote != nil:
-		// File present only on remote side. Copy to the local side.
-		if sf.remote.PutioFile().IsDir() {
-			return &createLocalFolderJob{
-				relpath:  sf.relpath,
-				remoteID: sf.remote.PutioFile().ID,
-			}
-		}
-		return &downloadJob{
-			remoteFile: sf.remote,
+		// File present only on remote side.
+		switch {
+		case opts.mode == modeMirrorLocal:
+			// File does not exist in local side. Delete it from remote side.
+			return []iJob{deleteRemote(sf)}
+		case opts.mode.downloads():
+			// Copy to the local side.
+			return []iJob{download(sf)}
+		default:
+			return nil
 		}
 	case sf.local != nil && sf.remote != nil:
 		// File exists on both sides
 		switch {
 		case sf.local.Info().IsDir() && sf.remote.Info().IsDir():
 			// Dir exists on both sides, save this state to db
-			return &writeDirStateJob{
+			return []iJob{&writeDirStateJob{
 				remoteID: sf.remote.PutioFile().ID,
 				relpath:  sf.remote.RelPath(),
-			}
+			}}
 		case sf.local.Info().IsDir() || sf.remote.Info().IsDir():
 			// One of the sides is a dir, the other is a file
+			if jobs := mirror(sf, opts); jobs != nil {
+				return jobs
+			}
+			if jobs := markConflict(sf, conflictKindDirFile, opts); jobs != nil {
+				return jobs
+			}
 			log.Warningf("Conflicting file, skipping sync: %q", sf.relpath)
 			return nil
 		// Both sides are file, not folder
 		case sf.local.Info().Size() != sf.remote.PutioFile().Size:
+			if jobs := mirror(sf, opts); jobs != nil {
+				return jobs
+			}
+			if jobs := markConflict(sf, conflictKindSizeMismatch, opts); jobs != nil {
+				return jobs
+			}
 			log.Warningf("File sizes differ, skipping sync: %q", sf.relpath)
 			return nil
+		case crc32Differs(sf.localCRC32, sf.remote.PutioFile().CRC32):
+			if jobs := mirror(sf, opts); jobs != nil {
+				return jobs
+			}
+			if jobs := markConflict(sf, conflictKindContentMismatch, opts); jobs != nil {
+				return jobs
+			}
+			log.Warningf("File contents differ, skipping sync: %q", sf.relpath)
+			return nil
 		default:
-			// Assume files are same if they are in same size
-			return &writeFileStateJob{
+			// Assume files are same if they are in same size and their checksums are not known
+			return []iJob{&writeFileStateJob{
 				localFile:  sf.local,
 				remoteFile: sf.remote,
-			}
+			}}
 		}
 	case sf.local == nil && sf.remote == nil:
 		return nil
@@ -104,7 +164,7 @@ func syncFresh(sf *syncFile) iJob {
 	}
 }
 
-func syncWithState(sf *syncFile, filesByRemoteID map[int64]*syncFile, filesByInode map[uint64]*syncFile) []iJob {
+func syncWithState(sf *syncFile, filesByRemoteID map[int64]*syncFile, filesByInode map[uint64]*syncFile, opts reconOptions) []iJob {
 	// We have a state from previous sync. Compare local and remote sides with existing state.
 	switch sf.state.Status {
 	case statusSynced:
@@ -117,34 +177,50 @@ func syncWithState(sf *syncFile, filesByRemoteID map[int64]*syncFile, filesByIno
 			}
 			if sf.local.Info().IsDir() || sf.remote.Info().IsDir() {
 				// One of the sides is a file
+				if jobs := mirror(sf, opts); jobs != nil {
+					return jobs
+				}
+				if jobs := markConflict(sf, conflictKindDirFile, opts); jobs != nil {
+					return jobs
+				}
 				log.Warningf("Conflicting file, one side is a directory, skipping sync: %q", sf.relpath)
 				return nil
 			}
-			if sf.state.Size != sf.local.Info().Size() && sf.state.Size != sf.remote.PutioFile().Size {
+			localChanged := sf.state.Size != sf.local.Info().Size() || crc32Differs(sf.localCRC32, sf.state.CRC32)
+			remoteChanged := sf.state.Size != sf.remote.PutioFile().Size || crc32Differs(sf.remote.PutioFile().CRC32, sf.state.CRC32)
+			if localChanged || remoteChanged {
+				if jobs := mirror(sf, opts); jobs != nil {
+					return jobs
+				}
+			}
+			if localChanged && remoteChanged {
+				if jobs := markConflict(sf, conflictKindBothModified, opts); jobs != nil {
+					return jobs
+				}
 				log.Warningf("Conflicting file, both files have changed, skipping sync: %q", sf.relpath)
 				return nil
 			}
-			if sf.state.Size != sf.local.Info().Size() {
+			if localChanged && opts.mode.uploads() {
 				// Local file has changed
-				return []iJob{
-					&uploadJob{
-						localFile: sf.local,
-					},
-				}
+				return []iJob{upload(sf)}
 			}
-			if sf.state.Size != sf.remote.PutioFile().Size {
+			if remoteChanged && opts.mode.downloads() {
 				// Remote file has changed
-				return []iJob{
-					&downloadJob{
-						remoteFile: sf.remote,
-					},
-				}
+				return []iJob{download(sf)}
 			}
-			// Assume files didn't change if their size didn't change
+			// Files didn't change if their size and checksum didn't change
 			// This is the most common case that is executed most because once all files are in sync no operations will be done later.
 			return nil
 		case sf.local != nil && sf.remote == nil:
 			// File missing in remote side, could be deleted or moved elsewhere
+			if opts.mode == modeMirrorLocal {
+				// Put the file back to remote side.
+				return []iJob{upload(sf)}
+			}
+			if !opts.mode.downloads() {
+				// Local files are not touched in this mode.
+				return nil
+			}
 			target, ok := filesByRemoteID[sf.state.RemoteID]
 			if ok { // nolint: nestif
 				// File with the same id is found on another path
@@ -173,6 +249,14 @@ func syncWithState(sf *syncFile, filesByRemoteID map[int64]*syncFile, filesByIno
 			}}
 		case sf.local == nil && sf.remote != nil:
 			// File missing in local side, could be deleted or moved elsewhere
+			if opts.mode == modeMirrorRemote {
+				// Put the file back to local side.
+				return []iJob{download(sf)}
+			}
+			if !opts.mode.uploads() {
+				// Remote files are not touched in this mode.
+				return nil
+			}
 			target, ok := filesByInode[sf.state.LocalInode]
 			if ok { // nolint: nestif
 				// File with same inode is found on another path
@@ -208,7 +292,7 @@ func syncWithState(sf *syncFile, filesByRemoteID map[int64]*syncFile, filesByIno
 			return nil
 		}
 	case statusDownloading:
-		if sf.local == nil && sf.remote != nil {
+		if sf.local == nil && sf.remote != nil && opts.mode.downloads() {
 			if sf.remote.PutioFile().CRC32 == sf.state.CRC32 {
 				// Remote file is still the same, resume download
 				return []iJob{&downloadJob{
@@ -218,14 +302,13 @@ func syncWithState(sf *syncFile, filesByRemoteID map[int64]*syncFile, filesByIno
 			}
 		}
 		// Cancel current download and make a new sync decision
-		return []iJob{
+		return append([]iJob{
 			&deleteStateJob{
 				state: *sf.state,
 			},
-			syncFresh(sf),
-		}
+		}, syncFresh(sf, opts)...)
 	case statusUploading:
-		if sf.local != nil && sf.remote == nil {
+		if sf.local != nil && sf.remote == nil && opts.mode.uploads() {
 			in, _ := inode.Get(sf.local.FullPath(), sf.local.Info())
 			if in == sf.state.LocalInode {
 				// Local file is still the same, resume upload
@@ -236,12 +319,11 @@ func syncWithState(sf *syncFile, filesByRemoteID map[int64]*syncFile, filesByIno
 			}
 		}
 		// Cancel current upload and make a new sync decision
-		return []iJob{
+		return append([]iJob{
 			&deleteStateJob{
 				state: *sf.state,
 			},
-			syncFresh(sf),
-		}
+		}, syncFresh(sf, opts)...)
 	default:
 		// Invalid status, should not happen in normal usage.
 		return []iJob{&deleteStateJob{
@@ -250,6 +332,72 @@ func syncWithState(sf *syncFile, filesByRemoteID map[int64]*syncFile, filesByIno
 	}
 }
 
+// mirror returns the jobs for making the file exist on both sides same in mirror modes.
+// Returns nil in other modes.
+func mirror(sf *syncFile, opts reconOptions) []iJob {
+	switch opts.mode {
+	case modeMirrorRemote:
+		if sf.local.Info().IsDir() != sf.remote.Info().IsDir() {
+			return []iJob{deleteLocal(sf), download(sf)}
+		}
+		return []iJob{download(sf)}
+	case modeMirrorLocal:
+		if sf.local.Info().IsDir() != sf.remote.Info().IsDir() {
+			return []iJob{deleteRemote(sf), upload(sf)}
+		}
+		return []iJob{upload(sf)}
+	default:
+		return nil
+	}
+}
+
+// upload returns the job that copies the local file or folder to remote side.
+func upload(sf *syncFile) iJob {
+	if sf.local.Info().IsDir() {
+		return &createRemoteFolderJob{
+			relpath: sf.relpath,
+		}
+	}
+	return &uploadJob{
+		localFile: sf.local,
+	}
+}
+
+// download returns the job that copies the remote file or folder to local side.
+func download(sf *syncFile) iJob {
+	if sf.remote.PutioFile().IsDir() {
+		return &createLocalFolderJob{
+			relpath:  sf.relpath,
+			remoteID: sf.remote.PutioFile().ID,
+		}
+	}
+	return &downloadJob{
+		remoteFile: sf.remote,
+	}
+}
+
+func deleteLocal(sf *syncFile) iJob {
+	state := stateType{relpath: sf.relpath}
+	if sf.state != nil {
+		state = *sf.state
+	}
+	return &deleteLocalFileJob{
+		localFile: sf.local,
+		state:     state,
+	}
+}
+
+func deleteRemote(sf *syncFile) iJob {
+	state := stateType{relpath: sf.relpath}
+	if sf.state != nil {
+		state = *sf.state
+	}
+	return &deleteRemoteFileJob{
+		remoteFile: sf.remote,
+		state:      state,
+	}
+}
+
 // sortSyncFiles so that folders come after regular files.
 // This is required for correct moving of folders with files inside.
 // First, files are synced, then folder can be moved or deleted.
@@ -1,11 +1,19 @@
 package putiosync
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/putdotio/go-putio"
+	"github.com/putdotio/putio-sync/v2/internal/inode"
+	"github.com/putdotio/putio-sync/v2/internal/trash"
+	"github.com/putdotio/putio-sync/v2/internal/walker"
 )
 
 func TestReconciliation(t *testing.T) {
@@ -19,7 +27,7 @@ func TestReconciliation(t *testing.T) {
 			remote:  fakeRemoteFile("bar"),
 		},
 	}
-	jobs := reconciliation(m)
+	jobs := reconciliation(m, reconOptions{mode: modeTwoWay})
 	if len(jobs) != 2 {
 		t.FailNow()
 	}
@@ -37,6 +45,383 @@ func TestReconciliation(t *testing.T) {
 	}
 }
 
+func TestReconciliationModes(t *testing.T) {
+	const missing = -1
+	cases := []struct {
+		name                string
+		local, remote, sync int64 // file sizes on each side and in state
+		jobs                map[syncMode][]string
+	}{
+		{
+			name: "local only", local: 1, remote: missing, sync: missing,
+			jobs: map[syncMode][]string{
+				modeTwoWay:       {"uploadJob"},
+				modeDownloadOnly: nil,
+				modeUploadOnly:   {"uploadJob"},
+				modeMirrorRemote: {"deleteLocalFileJob"},
+				modeMirrorLocal:  {"uploadJob"},
+			},
+		},
+		{
+			name: "remote only", local: missing, remote: 1, sync: missing,
+			jobs: map[syncMode][]string{
+				modeTwoWay:       {"downloadJob"},
+				modeDownloadOnly: {"downloadJob"},
+				modeUploadOnly:   nil,
+				modeMirrorRemote: {"downloadJob"},
+				modeMirrorLocal:  {"deleteRemoteFileJob"},
+			},
+		},
+		{
+			name: "sizes differ", local: 1, remote: 2, sync: missing,
+			jobs: map[syncMode][]string{
+				modeTwoWay:       nil,
+				modeDownloadOnly: nil,
+				modeUploadOnly:   nil,
+				modeMirrorRemote: {"downloadJob"},
+				modeMirrorLocal:  {"uploadJob"},
+			},
+		},
+		{
+			name: "same size", local: 1, remote: 1, sync: missing,
+			jobs: map[syncMode][]string{
+				modeTwoWay:       {"writeFileStateJob"},
+				modeDownloadOnly: {"writeFileStateJob"},
+				modeUploadOnly:   {"writeFileStateJob"},
+				modeMirrorRemote: {"writeFileStateJob"},
+				modeMirrorLocal:  {"writeFileStateJob"},
+			},
+		},
+		{
+			name: "local changed", local: 2, remote: 1, sync: 1,
+			jobs: map[syncMode][]string{
+				modeTwoWay:       {"uploadJob"},
+				modeDownloadOnly: nil,
+				modeUploadOnly:   {"uploadJob"},
+				modeMirrorRemote: {"downloadJob"},
+				modeMirrorLocal:  {"uploadJob"},
+			},
+		},
+		{
+			name: "remote changed", local: 1, remote: 2, sync: 1,
+			jobs: map[syncMode][]string{
+				modeTwoWay:       {"downloadJob"},
+				modeDownloadOnly: {"downloadJob"},
+				modeUploadOnly:   nil,
+				modeMirrorRemote: {"downloadJob"},
+				modeMirrorLocal:  {"uploadJob"},
+			},
+		},
+		{
+			name: "both changed", local: 2, remote: 3, sync: 1,
+			jobs: map[syncMode][]string{
+				modeTwoWay:       nil,
+				modeDownloadOnly: nil,
+				modeUploadOnly:   nil,
+				modeMirrorRemote: {"downloadJob"},
+				modeMirrorLocal:  {"uploadJob"},
+			},
+		},
+		{
+			name: "local deleted", local: missing, remote: 1, sync: 1,
+			jobs: map[syncMode][]string{
+				modeTwoWay:       {"deleteRemoteFileJob"},
+				modeDownloadOnly: nil,
+				modeUploadOnly:   {"deleteRemoteFileJob"},
+				modeMirrorRemote: {"downloadJob"},
+				modeMirrorLocal:  {"deleteRemoteFileJob"},
+			},
+		},
+		{
+			name: "remote deleted", local: 1, remote: missing, sync: 1,
+			jobs: map[syncMode][]string{
+				modeTwoWay:       {"deleteLocalFileJob"},
+				modeDownloadOnly: {"deleteLocalFileJob"},
+				modeUploadOnly:   nil,
+				modeMirrorRemote: {"deleteLocalFileJob"},
+				modeMirrorLocal:  {"uploadJob"},
+			},
+		},
+		{
+			name: "deleted on both sides", local: missing, remote: missing, sync: 1,
+			jobs: map[syncMode][]string{
+				modeTwoWay:       {"deleteStateJob"},
+				modeDownloadOnly: {"deleteStateJob"},
+				modeUploadOnly:   {"deleteStateJob"},
+				modeMirrorRemote: {"deleteStateJob"},
+				modeMirrorLocal:  {"deleteStateJob"},
+			},
+		},
+	}
+	for _, c := range cases {
+		for mode, expected := range c.jobs {
+			sf := &syncFile{relpath: "foo"}
+			if c.local != missing {
+				sf.local = fakeLocalFileSize(t, "foo", c.local)
+			}
+			if c.remote != missing {
+				rf := fakeRemoteFile("foo")
+				rf.putioFile.ID = 1
+				rf.putioFile.Size = c.remote
+				sf.remote = rf
+			}
+			if c.sync != missing {
+				sf.state = &stateType{Status: statusSynced, RemoteID: 1, Size: c.sync, relpath: "foo"}
+			}
+			jobs := reconciliation(map[string]*syncFile{"foo": sf}, reconOptions{mode: mode})
+			names := jobNames(jobs)
+			if !reflect.DeepEqual(names, expected) {
+				t.Errorf("%s in %s mode: expected %v, got %v", c.name, mode, expected, names)
+			}
+		}
+	}
+}
+
+func TestConflictPolicies(t *testing.T) {
+	cases := []struct {
+		mode     syncMode
+		policy   conflictPolicy
+		remoteAt time.Time
+		jobs     []string
+	}{
+		{modeTwoWay, conflictSkip, time.Time{}, nil},
+		{modeTwoWay, conflictLocalWins, time.Time{}, []string{"overwriteRemoteJob"}},
+		{modeTwoWay, conflictRemoteWins, time.Time{}, []string{"overwriteLocalJob"}},
+		{modeTwoWay, conflictNewestWins, time.Now().Add(-time.Hour), []string{"overwriteRemoteJob"}},
+		{modeTwoWay, conflictNewestWins, time.Now().Add(time.Hour), []string{"overwriteLocalJob"}},
+		{modeTwoWay, conflictKeepBoth, time.Time{}, []string{"keepBothJob"}},
+		{modeDownloadOnly, conflictLocalWins, time.Time{}, nil},
+		{modeDownloadOnly, conflictRemoteWins, time.Time{}, []string{"overwriteLocalJob"}},
+		{modeUploadOnly, conflictRemoteWins, time.Time{}, nil},
+		{modeUploadOnly, conflictKeepBoth, time.Time{}, []string{"keepBothJob"}},
+	}
+	for _, c := range cases {
+		rf := fakeRemoteFile("foo.txt")
+		rf.putioFile.ID = 1
+		rf.putioFile.Size = 3
+		rf.putioFile.UpdatedAt = &putio.Time{Time: c.remoteAt}
+		sf := &syncFile{
+			relpath: "foo.txt",
+			local:   fakeLocalFileSize(t, "foo.txt", 2),
+			remote:  rf,
+			state:   &stateType{Status: statusSynced, RemoteID: 1, Size: 1, relpath: "foo.txt"},
+		}
+		opts := reconOptions{mode: c.mode, conflictPolicy: c.policy, hostname: "host", now: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)}
+		jobs := reconciliation(map[string]*syncFile{"foo.txt": sf}, opts)
+		names := jobNames(jobs)
+		for _, j := range jobs {
+			if kb, ok := j.(*keepBothJob); ok {
+				if kb.toRelpath != "foo (conflict from host 2023-01-02 030405).txt" {
+					t.Errorf("unexpected conflict name: %q", kb.toRelpath)
+				}
+				if kb.renameRemote != (c.mode == modeUploadOnly) {
+					t.Errorf("unexpected side renamed in %s mode", c.mode)
+				}
+			}
+		}
+		if !reflect.DeepEqual(names, c.jobs) {
+			t.Errorf("%s policy in %s mode: expected %v, got %v", c.policy, c.mode, c.jobs, names)
+		}
+	}
+}
+
+func TestConflictResolutions(t *testing.T) {
+	newFiles := func() map[string]*syncFile {
+		rf := fakeRemoteFile("foo.txt")
+		rf.putioFile.ID = 1
+		rf.putioFile.Size = 3
+		dup := fakeRemoteFile("bar.txt")
+		dup.putioFile.ID = 2
+		return map[string]*syncFile{
+			"foo.txt": {
+				relpath: "foo.txt",
+				local:   fakeLocalFileSize(t, "foo.txt", 2),
+				remote:  rf,
+			},
+			"bar.txt": {
+				relpath:    "bar.txt",
+				remote:     fakeRemoteFile("bar.txt"),
+				duplicates: []iRemoteFile{dup},
+			},
+		}
+	}
+
+	// Conflicts are recorded when they are skipped.
+	m := newFiles()
+	jobs := reconciliation(m, reconOptions{mode: modeDownloadOnly, conflictPolicy: conflictSkip})
+	if len(jobs) != 1 {
+		t.Fatalf("expected only download job, got %v", jobs)
+	}
+	if m["foo.txt"].conflict != conflictKindSizeMismatch || m["foo.txt"].conflictResolved {
+		t.Errorf("unexpected conflict for foo.txt: %q", m["foo.txt"].conflict)
+	}
+	if m["bar.txt"].conflict != conflictKindNameCollision || m["bar.txt"].conflictResolved {
+		t.Errorf("unexpected conflict for bar.txt: %q", m["bar.txt"].conflict)
+	}
+
+	// Actions set by the user take precedence over the policy.
+	m = newFiles()
+	opts := reconOptions{
+		mode:           modeTwoWay,
+		conflictPolicy: conflictSkip,
+		resolutions:    map[string]string{"foo.txt": resolveKeepRemote, "bar.txt": resolveKeepBoth},
+	}
+	jobs = reconciliation(m, opts)
+	names := jobNames(jobs)
+	expected := []string{"downloadJob", "overwriteLocalJob", "renameDuplicateJob"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+	if !m["foo.txt"].conflictResolved || !m["bar.txt"].conflictResolved {
+		t.Error("conflicts must be marked as resolved")
+	}
+}
+
+func TestChecksumChanges(t *testing.T) {
+	cases := []struct {
+		name   string
+		local  string
+		remote string
+		state  string
+		jobs   []string
+	}{
+		{"unchanged", "aaaaaaaa", "aaaaaaaa", "aaaaaaaa", nil},
+		{"local changed", "bbbbbbbb", "aaaaaaaa", "aaaaaaaa", []string{"uploadJob"}},
+		{"remote changed", "aaaaaaaa", "bbbbbbbb", "aaaaaaaa", []string{"downloadJob"}},
+		{"both changed", "bbbbbbbb", "cccccccc", "aaaaaaaa", nil},
+		{"local not hashed", "", "aaaaaaaa", "aaaaaaaa", nil},
+		{"first seen with different contents", "bbbbbbbb", "aaaaaaaa", "", nil},
+		{"first seen with same contents", "aaaaaaaa", "AAAAAAAA", "", []string{"writeFileStateJob"}},
+	}
+	for _, c := range cases {
+		rf := fakeRemoteFile("foo")
+		rf.putioFile.ID = 1
+		rf.putioFile.Size = 1
+		rf.putioFile.CRC32 = c.remote
+		sf := &syncFile{
+			relpath:    "foo",
+			local:      fakeLocalFileSize(t, "foo", 1),
+			remote:     rf,
+			localCRC32: c.local,
+		}
+		if c.state != "" {
+			sf.state = &stateType{Status: statusSynced, RemoteID: 1, Size: 1, CRC32: c.state, relpath: "foo"}
+		}
+		jobs := reconciliation(map[string]*syncFile{"foo": sf}, reconOptions{mode: modeTwoWay, conflictPolicy: conflictSkip})
+		names := jobNames(jobs)
+		if !reflect.DeepEqual(names, c.jobs) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.jobs, names)
+		}
+	}
+}
+
+func TestFilterOutExcluded(t *testing.T) {
+	state := func(relpath string) *stateType {
+		return &stateType{Status: statusSynced, Size: 1, relpath: relpath}
+	}
+	m := map[string]*syncFile{
+		"a":       {relpath: "a", state: state("a")},
+		"a/b":     {relpath: "a/b", state: state("a/b")},
+		"a/b/foo": {relpath: "a/b/foo", state: state("a/b/foo")},
+		"a/c":     {relpath: "a/c", state: state("a/c")},
+		"a/c/bar": {relpath: "a/c/bar", state: state("a/c/bar")},
+		"d":       {relpath: "d", remote: fakeRemoteFile("d")},
+	}
+	jobs := filterOutExcluded(m, walker.NewSelection([]string{"/a/b/"}), excludeActionKeep, modeTwoWay)
+	if len(m) != 3 {
+		t.Fatalf("unexpected files after filter: %v", m)
+	}
+	if len(jobs) != 1 {
+		t.Fatalf("unexpected number of jobs: %d", len(jobs))
+	}
+	j, ok := jobs[0].(*excludeFolderJob)
+	if !ok || j.relpath != "a/c" || j.remove || len(j.states) != 2 {
+		t.Fatalf("unexpected job: %#v", jobs[0])
+	}
+	for _, job := range reconciliation(m, reconOptions{mode: modeTwoWay}) {
+		switch job.(type) {
+		case *deleteLocalFileJob, *deleteRemoteFileJob:
+			t.Fatalf("excluded file is deleted: %s", job)
+		}
+	}
+	m = map[string]*syncFile{"e": {relpath: "e", state: state("e")}}
+	jobs = filterOutExcluded(m, walker.NewSelection([]string{"/a/"}), excludeActionRemove, modeUploadOnly)
+	if j, ok := jobs[0].(*excludeFolderJob); !ok || j.remove || j.isDir || j.String() != `Keeping excluded file "e"` {
+		t.Fatalf("local file is removed in upload-only mode: %#v", jobs[0])
+	}
+}
+
+func TestExcludeFolderJob(t *testing.T) {
+	openTestDB(t)
+	folder = FolderConfig{Name: "test"}
+	localPath = t.TempDir()
+	trashBin = trash.New(localPath)
+	defer func() { folder, localPath, trashBin = FolderConfig{}, "", nil }()
+	if err := createPairBuckets(); err != nil {
+		t.Fatal(err)
+	}
+	write := func(relpath, content string) {
+		name := filepath.Join(localPath, filepath.FromSlash(relpath))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	synced := func(relpath string) stateType {
+		name := filepath.Join(localPath, filepath.FromSlash(relpath))
+		fi, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		in, _ := inode.Get(name, fi)
+		sum, _ := fileCRC32(context.Background(), name)
+		return stateType{Status: statusSynced, Size: fi.Size(), LocalInode: in, CRC32: sum, relpath: relpath}
+	}
+	write("a/same", "foo")
+	write("a/sub/same", "bar")
+	write("a/changed", "baz")
+	write("a/new", "new")
+	states := []stateType{
+		{Status: statusSynced, IsDir: true, relpath: "a"},
+		{Status: statusSynced, IsDir: true, relpath: "a/sub"},
+		synced("a/same"),
+		synced("a/sub/same"),
+		synced("a/changed"),
+	}
+	write("a/changed", "qux")
+	j := &excludeFolderJob{relpath: "a", isDir: true, remove: true, states: states}
+	if paths := deletedPaths(j); len(paths) != 3 {
+		t.Errorf("unexpected deleted paths: %v", paths)
+	}
+	if err := j.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for relpath, exists := range map[string]bool{"a/same": false, "a/sub": false, "a/changed": true, "a/new": true} {
+		_, err := os.Stat(filepath.Join(localPath, filepath.FromSlash(relpath)))
+		if exists != (err == nil) {
+			t.Errorf("%s: expected exists=%v, got error: %v", relpath, exists, err)
+		}
+	}
+	items, err := trashBin.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Errorf("unexpected items in trash: %v", items)
+	}
+}
+
+// jobNames returns the type names of jobs, e.g. "uploadJob".
+func jobNames(jobs []iJob) []string {
+	var names []string
+	for _, j := range jobs {
+		names = append(names, strings.TrimPrefix(fmt.Sprintf("%T", j), "*putiosync."))
+	}
+	return names
+}
+
 type FakeLocalFile struct {
 	info     os.FileInfo
 	relpath  string
@@ -44,12 +429,20 @@ type FakeLocalFile struct {
 }
 
 func fakeLocalFile(t *testing.T, relpath string) *FakeLocalFile {
+	return fakeLocalFileSize(t, relpath, 0)
+}
+
+func fakeLocalFileSize(t *testing.T, relpath string, size int64) *FakeLocalFile {
 	f, err := os.CreateTemp("", "")
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer os.Remove(f.Name())
 	defer f.Close()
+	err = f.Truncate(size)
+	if err != nil {
+		t.Fatal(err)
+	}
 	fi, err := f.Stat()
 	if err != nil {
 		t.Fatal(err)
@@ -0,0 +1,245 @@
+package putiosync
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/putdotio/putio-sync/v2/internal/ignore"
+	"github.com/putdotio/putio-sync/v2/internal/updates"
+	"github.com/putdotio/putio-sync/v2/internal/walker"
+)
+
+const (
+	// Remote folder is walked completely at least this often, unless FullWalkInterval is set in config.
+	defaultFullWalkInterval = 6 * time.Hour
+	// Remote folder is walked completely if more events than this are received between syncs of a sync pair.
+	maxRemoteEvents = 10000
+)
+
+// Job types that do not change remote files. Other jobs cause their remote folders to be listed on next sync.
+var localJobTypes = map[string]struct{}{
+	"download":            {},
+	"overwrite-local":     {},
+	"move-local":          {},
+	"delete-local":        {},
+	"create-local-folder": {},
+	"exclude-folder":      {},
+	"save-state":          {},
+	"delete-state":        {},
+}
+
+// remoteTree is the list of remote files of a sync pair from the last sync.
+// When changes are received as events, only the changed remote folders are listed and the tree is updated with them.
+type remoteTree struct {
+	rootID int64
+	// Selected folders and ignore rules when the tree is listed. Tree is walked again if they change.
+	include   string
+	ignoreSig string
+	files     []*walker.RemoteFile
+	ignored   []string
+	// Time of the last full walk.
+	walkedAt time.Time
+	// Events received since the last sync of the pair. Complete is false if some events may be missed.
+	events   []updates.Event
+	complete bool
+	// Paths changed by the jobs of the last sync.
+	changed []string
+	// Folders in files keyed by path, set by changedDirs.
+	dirs map[string]*walker.RemoteFile
+}
+
+// remoteTrees are keyed by sync pair name. They are accessed only by the goroutine running Sync.
+var remoteTrees = make(map[string]*remoteTree)
+
+func fullWalkInterval() time.Duration {
+	if cfg.FullWalkInterval > 0 {
+		return cfg.FullWalkInterval
+	}
+	return defaultFullWalkInterval
+}
+
+// takeRemoteEvents takes the events received from the notifier and adds them to the trees of all sync pairs.
+func takeRemoteEvents() {
+	events, complete := notifier.TakeEvents()
+	for _, t := range remoteTrees {
+		if !complete || len(t.events)+len(events) > maxRemoteEvents {
+			t.events = nil
+			t.complete = false
+			continue
+		}
+		t.events = append(t.events, events...)
+	}
+}
+
+// rememberChanges saves the paths changed by the jobs, so their remote folders are listed on next sync
+// even if the events of the changes are not received by then.
+func rememberChanges(jobs []iJob) {
+	t := remoteTrees[folder.Name]
+	if t == nil {
+		return
+	}
+	for _, job := range jobs {
+		if _, ok := localJobTypes[newPlanEntry(job, nil).Type]; !ok {
+			t.changed = append(t.changed, job.Paths()...)
+		}
+	}
+}
+
+// changedDirs returns the remote folders that must be listed for updating the tree.
+// Returns false if the whole remote folder must be walked instead.
+func (t *remoteTree) changedDirs(rootID int64, include string) ([]walker.RemoteDir, bool) {
+	if t == nil || !t.complete || t.rootID != rootID || t.include != include || time.Since(t.walkedAt) > fullWalkInterval() {
+		return nil, false
+	}
+	byID := make(map[int64]*walker.RemoteFile, len(t.files))
+	t.dirs = make(map[string]*walker.RemoteFile)
+	for _, f := range t.files {
+		byID[f.PutioFile().ID] = f
+		if f.PutioFile().IsDir() {
+			t.dirs[f.RelPath()] = f
+		}
+	}
+	changed := make(map[string]walker.RemoteDir)
+	// add marks the nearest folder of relpath in the tree. New folders inside it are walked when it is listed.
+	add := func(relpath string) {
+		for ; relpath != "." && relpath != "/"; relpath = path.Dir(relpath) {
+			if d, ok := t.dirs[relpath]; ok {
+				changed[relpath] = walker.RemoteDir{RelPath: relpath, ID: d.PutioFile().ID, ParentID: d.PutioFile().ParentID}
+				return
+			}
+		}
+		changed["."] = walker.RemoteDir{RelPath: ".", ID: rootID}
+	}
+	for _, e := range t.events {
+		// Old location of a known file is listed, so it is removed from there if it is moved or deleted.
+		f, known := byID[e.ID]
+		if known {
+			add(path.Dir(f.RelPath()))
+		}
+		switch {
+		case e.ID == rootID || e.ParentID == rootID:
+			add(".")
+		case e.ParentID != 0:
+			// Events of files in other folders of the account are ignored.
+			if p, ok := byID[e.ParentID]; ok && p.PutioFile().IsDir() {
+				add(p.RelPath())
+			}
+		case !known:
+			// New location of the file cannot be found without its parent.
+			return nil, false
+		}
+	}
+	for _, p := range t.changed {
+		add(path.Dir(p))
+	}
+	dirs := make([]walker.RemoteDir, 0, len(changed))
+	for _, d := range changed {
+		dirs = append(dirs, d)
+	}
+	sort.Slice(dirs, func(i, j int) bool { return dirs[i].RelPath < dirs[j].RelPath })
+	return dirs, true
+}
+
+// known reports whether the folder is in the tree, so its contents do not need to be listed.
+// Folders that are changed are listed separately.
+func (t *remoteTree) known(relpath string, id int64) bool {
+	d, ok := t.dirs[relpath]
+	return ok && d.PutioFile().ID == id
+}
+
+// update replaces the contents of the listed folders in the tree with the new listing.
+// Folders that are not in the new listing of their parent are removed with their contents.
+func (t *remoteTree) update(dirs []walker.RemoteDir, listed []*walker.RemoteFile, ignored []string) {
+	listedDirs := make(map[string]int64)
+	for _, f := range listed {
+		if f.PutioFile().IsDir() {
+			listedDirs[f.RelPath()] = f.PutioFile().ID
+		}
+	}
+	// Folders that are skipped by the walker because they are moved or deleted are not replaced.
+	relisted := make(map[string]bool, len(dirs))
+	for _, d := range dirs {
+		if d.RelPath == "." || listedDirs[d.RelPath] == d.ID {
+			relisted[d.RelPath] = true
+		}
+	}
+	removedDirs := make(map[string]bool)
+	for _, f := range t.files {
+		if f.PutioFile().IsDir() && relisted[path.Dir(f.RelPath())] && listedDirs[f.RelPath()] != f.PutioFile().ID {
+			removedDirs[f.RelPath()] = true
+		}
+	}
+	replaced := func(relpath string) bool {
+		if relisted[relpath] {
+			return true
+		}
+		for dir := path.Dir(relpath); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			if removedDirs[dir] {
+				return true
+			}
+		}
+		return relisted[path.Dir(relpath)]
+	}
+
+	// Same file can be listed more than once if both a folder and its parent are listed.
+	seen := make(map[string]struct{})
+	files := make([]*walker.RemoteFile, 0, len(t.files)+len(listed))
+	addFile := func(f *walker.RemoteFile) {
+		key := fmt.Sprintf("%s:%d", f.RelPath(), f.PutioFile().ID)
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			files = append(files, f)
+		}
+	}
+	for _, f := range t.files {
+		if !replaced(f.RelPath()) {
+			addFile(f)
+		}
+	}
+	for _, f := range listed {
+		addFile(f)
+	}
+	// Latest uploaded file must be the last one among the files with the same name, see groupFiles.
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].RelPath() != files[j].RelPath() {
+			return files[i].RelPath() < files[j].RelPath()
+		}
+		return files[i].PutioFile().ID < files[j].PutioFile().ID
+	})
+	t.files = files
+
+	ignoredSet := make(map[string]struct{}, len(t.ignored)+len(ignored))
+	newIgnored := make([]string, 0, len(t.ignored)+len(ignored))
+	addIgnored := func(p string) {
+		if _, ok := ignoredSet[p]; !ok {
+			ignoredSet[p] = struct{}{}
+			newIgnored = append(newIgnored, p)
+		}
+	}
+	for _, p := range t.ignored {
+		if !replaced(p) {
+			addIgnored(p)
+		}
+	}
+	for _, p := range ignored {
+		addIgnored(p)
+	}
+	t.ignored = newIgnored
+}
+
+// ignoreSignature identifies the ignore rules that the tree is filtered with.
+// Remote folder is walked again if a rule is changed.
+func ignoreSignature(localFiles []*walker.LocalFile) string {
+	h := fnv.New64a()
+	fmt.Fprintln(h, strings.Join(cfg.Ignore, "\n"))
+	for _, lf := range localFiles {
+		if lf.Info().Name() == ignore.FileName {
+			fmt.Fprintln(h, lf.RelPath(), lf.Info().Size(), lf.Info().ModTime().UnixNano())
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum64())
+}
@@ -0,0 +1,82 @@
+package putiosync
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/putdotio/go-putio"
+	"github.com/putdotio/putio-sync/v2/internal/updates"
+	"github.com/putdotio/putio-sync/v2/internal/walker"
+)
+
+func TestRemoteTreeUpdate(t *testing.T) {
+	const rootID = 1
+	dir := func(relpath string, id, parentID int64) *walker.RemoteFile {
+		return walker.NewRemoteFile(putio.File{ID: id, ParentID: parentID, ContentType: "application/x-directory"}, relpath)
+	}
+	file := func(relpath string, id, parentID int64) *walker.RemoteFile {
+		return walker.NewRemoteFile(putio.File{ID: id, ParentID: parentID}, relpath)
+	}
+	tree := &remoteTree{
+		rootID:   rootID,
+		walkedAt: time.Now(),
+		complete: true,
+		files: []*walker.RemoteFile{
+			dir("a", 2, rootID),
+			file("a/x", 3, 2),
+			dir("a/sub", 4, 2),
+			file("a/sub/y", 5, 4),
+			dir("b", 6, rootID),
+			file("b/z", 7, 6),
+			file("c.txt", 8, rootID),
+		},
+		events: []updates.Event{
+			{Type: "file_create", ID: 9, ParentID: 6},
+			// a/sub is moved into b.
+			{Type: "file_update", ID: 4, ParentID: 6},
+			{Type: "file_delete", ID: 8},
+			// Outside of the synced folder.
+			{Type: "file_create", ID: 100, ParentID: 555},
+		},
+	}
+	dirs, ok := tree.changedDirs(rootID, "")
+	if !ok {
+		t.Fatal("tree must be updated without a full walk")
+	}
+	expectedDirs := []walker.RemoteDir{{RelPath: ".", ID: rootID}, {RelPath: "a", ID: 2, ParentID: rootID}, {RelPath: "b", ID: 6, ParentID: rootID}}
+	if !reflect.DeepEqual(dirs, expectedDirs) {
+		t.Fatalf("expected dirs %v, got %v", expectedDirs, dirs)
+	}
+	if !tree.known("a", 2) || tree.known("b/sub", 4) {
+		t.Error("only folders at the same path with the same ID must be known")
+	}
+	listed := []*walker.RemoteFile{
+		// Listing of root, contents of known folders are not listed.
+		dir("a", 2, rootID),
+		dir("b", 6, rootID),
+		// Listing of a.
+		dir("a", 2, rootID),
+		file("a/x", 3, 2),
+		// Listing of b, moved folder is walked.
+		dir("b", 6, rootID),
+		file("b/w", 9, 6),
+		file("b/z", 7, 6),
+		dir("b/sub", 4, 6),
+		file("b/sub/y", 5, 4),
+	}
+	tree.update(dirs, listed, nil)
+	var paths []string
+	for _, f := range tree.files {
+		paths = append(paths, f.RelPath())
+	}
+	expected := []string{"a", "a/x", "b", "b/sub", "b/sub/y", "b/w", "b/z"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected %v, got %v", expected, paths)
+	}
+
+	tree.events = []updates.Event{{Type: "file_create", ID: 10}}
+	if _, ok = tree.changedDirs(rootID, ""); ok {
+		t.Error("unknown file without parent must require a full walk")
+	}
+}
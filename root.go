@@ -2,46 +2,210 @@ package putiosync
 
 import (
 	"context"
-	"os"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
 
+	"github.com/cenkalti/log"
 	"github.com/putdotio/go-putio"
 	"github.com/syncthing/syncthing/lib/fs"
 )
 
+// remoteFolderName is the default remote folder of a sync pair.
 const remoteFolderName = "putio-sync"
 
+// remoteRoot is the resolved remote folder of a sync pair.
+// It is saved in database so that the same folder is used in later runs even if the path becomes ambiguous.
+// Name and parent are saved to detect if the folder is renamed or moved.
+// Path is empty if the folder is set with RemoteFolderID in config.
+type remoteRoot struct {
+	ID       int64
+	Path     string
+	Name     string
+	ParentID int64
+}
+
+const metaRemoteRoot = "remoteRoot"
+
+var errRemotePathNotFound = errors.New("remote folder does not exist")
+
+// Roots that do not exist yet in dry-run mode. They are created on first sync, so they are planned as empty.
+var localRootMissing, remoteRootMissing bool
+
 func ensureRoots(baseCtx context.Context) error {
+	localRootMissing, remoteRootMissing = false, false
 	var err error
-	localPath, err = fs.ExpandTilde(cfg.LocalDir)
+	localPath, err = fs.ExpandTilde(folder.LocalDir)
 	if err != nil {
 		return err
 	}
-	err = os.MkdirAll(localPath, 0777)
+	err = ensureLocalRoot()
 	if err != nil {
 		return err
 	}
-	ctx, cancel := context.WithTimeout(baseCtx, defaultTimeout)
-	defer cancel()
-	folders, _, err := client.Files.List(ctx, 0)
+	var root remoteRoot
+	found, err := readMeta(metaRemoteRoot, &root)
+	if err != nil {
+		return err
+	}
+	// Files synced by a previous version or into another folder must not be deleted because the folder is changed.
+	synced, err := hasStates()
 	if err != nil {
 		return err
 	}
-	found := false
-	var f putio.File
-	for _, f = range folders {
-		if f.IsDir() && f.Name == remoteFolderName {
-			found = true
-			break
+	if folder.RemoteFolderID != 0 {
+		if found && root.ID == folder.RemoteFolderID {
+			return checkRemoteRoot(baseCtx, root)
+		}
+		if found && synced {
+			return &rootError{fmt.Sprintf("remote folder is changed from %s to id %d", root, folder.RemoteFolderID)}
+		}
+		f, err := getRemoteFolder(baseCtx, folder.RemoteFolderID)
+		if err != nil {
+			return err
 		}
+		remoteFolderID = f.ID
+		return saveRemoteRoot(remoteRoot{ID: f.ID, Name: f.Name, ParentID: f.ParentID})
+	}
+	if found && root.ID != 0 && root.Path == folder.RemotePath {
+		return checkRemoteRoot(baseCtx, root)
+	}
+	if found && synced {
+		return &rootError{fmt.Sprintf("remote folder is changed from %s to %q", root, folder.RemotePath)}
+	}
+	if !found && synced {
+		_, err = lookupRemotePath(baseCtx, folder.RemotePath, false)
+		if errors.Is(err, errRemotePathNotFound) {
+			return &rootError{fmt.Sprintf("remote folder %q does not exist", folder.RemotePath)}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	id, err := lookupRemotePath(baseCtx, folder.RemotePath, !cfg.DryRun)
+	if errors.Is(err, errRemotePathNotFound) && cfg.DryRun {
+		// Folder is created on first sync, plan it as empty.
+		remoteFolderID = 0
+		remoteRootMissing = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	f, err := getRemoteFolder(baseCtx, id)
+	if err != nil {
+		return err
+	}
+	remoteFolderID = f.ID
+	return saveRemoteRoot(remoteRoot{ID: f.ID, Path: folder.RemotePath, Name: f.Name, ParentID: f.ParentID})
+}
+
+// saveRemoteRoot remembers the remote folder of the current sync pair. Nothing is saved in dry-run mode.
+func saveRemoteRoot(root remoteRoot) error {
+	if cfg.DryRun {
+		return nil
+	}
+	return writeMeta(metaRemoteRoot, root)
+}
+
+func (r remoteRoot) String() string {
+	if r.Path == "" {
+		return fmt.Sprintf("id %d", r.ID)
+	}
+	return fmt.Sprintf("%q with id %d", r.Path, r.ID)
+}
+
+// checkRemoteRoot checks that the remote folder saved in database still exists at the same place.
+// Otherwise sync is stopped, because a missing root would make all synced files look deleted on remote.
+func checkRemoteRoot(baseCtx context.Context, root remoteRoot) error {
+	f, err := getRemoteFolder(baseCtx, root.ID)
+	if isNotFound(err) {
+		return &rootError{fmt.Sprintf("remote folder %s is deleted or moved to trash", root)}
+	}
+	if err != nil {
+		return err
 	}
-	if !found {
-		ctx, cancel = context.WithTimeout(baseCtx, defaultTimeout)
-		defer cancel()
-		f, err = client.Files.CreateFolder(ctx, remoteFolderName, 0)
+	if root.Name == "" {
+		// Saved by a previous version.
+		root.Name, root.ParentID = f.Name, f.ParentID
+		err = saveRemoteRoot(root)
 		if err != nil {
 			return err
 		}
 	}
+	if f.Name != root.Name {
+		return &rootError{fmt.Sprintf("remote folder %s is renamed to %q", root, f.Name)}
+	}
+	if f.ParentID != root.ParentID {
+		return &rootError{fmt.Sprintf("remote folder %s is moved to another folder", root)}
+	}
 	remoteFolderID = f.ID
 	return nil
 }
+
+func getRemoteFolder(baseCtx context.Context, id int64) (putio.File, error) {
+	ctx, cancel := context.WithTimeout(baseCtx, defaultTimeout)
+	defer cancel()
+	f, err := client.Files.Get(ctx, id)
+	if err != nil {
+		return f, err
+	}
+	if !f.IsDir() {
+		return f, fmt.Errorf("remote file with id %d is not a folder", id)
+	}
+	return f, nil
+}
+
+// lookupRemotePath walks the slash separated path starting from the root of put.io account and returns the ID of the last folder.
+// Missing folders are created if create is true, otherwise a not found error is returned.
+func lookupRemotePath(baseCtx context.Context, p string, create bool) (int64, error) {
+	var id int64
+	for _, name := range strings.Split(strings.Trim(p, "/"), "/") {
+		if name == "" {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(baseCtx, defaultTimeout)
+		children, _, err := client.Files.List(ctx, id)
+		cancel()
+		if err != nil {
+			return 0, err
+		}
+		var matches []putio.File
+		for _, f := range children {
+			if f.IsDir() && f.Name == name {
+				matches = append(matches, f)
+			}
+		}
+		if len(matches) == 0 {
+			if !create {
+				return 0, errRemotePathNotFound
+			}
+			ctx, cancel = context.WithTimeout(baseCtx, defaultTimeout)
+			f, err := client.Files.CreateFolder(ctx, name, id)
+			cancel()
+			if err != nil {
+				return 0, err
+			}
+			id = f.ID
+			continue
+		}
+		// Pick the oldest folder if there are many folders with the same name.
+		f := matches[0]
+		for _, m := range matches[1:] {
+			if m.ID < f.ID {
+				f = m
+			}
+		}
+		if len(matches) > 1 {
+			log.Warningf("There are %d folders named %q, using the one with id %d", len(matches), name, f.ID)
+		}
+		id = f.ID
+	}
+	return id, nil
+}
+
+func isNotFound(err error) bool {
+	var errResp *putio.ErrorResponse
+	return errors.As(err, &errResp) && errResp.Response.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,41 @@
+package putiosync
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestEnsureRootsChanged(t *testing.T) {
+	cases := []struct {
+		name  string
+		saved remoteRoot
+		path  string
+		id    int64
+	}{
+		{"path changed", remoteRoot{ID: 1, Path: "old", Name: "old"}, "new", 0},
+		{"path to id", remoteRoot{ID: 1, Path: "old", Name: "old"}, "", 2},
+		{"id changed", remoteRoot{ID: 1, Name: "old"}, "", 2},
+		{"id to path", remoteRoot{ID: 1, Name: "old"}, "new", 0},
+	}
+	defer func() { folder = FolderConfig{} }()
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			openTestDB(t)
+			folder = FolderConfig{Name: "test", LocalDir: t.TempDir(), RemotePath: c.path, RemoteFolderID: c.id}
+			if err := createPairBuckets(); err != nil {
+				t.Fatal(err)
+			}
+			if err := writeMeta(metaRemoteRoot, c.saved); err != nil {
+				t.Fatal(err)
+			}
+			if err := (stateType{Status: statusSynced, relpath: "foo"}).Write(); err != nil {
+				t.Fatal(err)
+			}
+			var rerr *rootError
+			if err := ensureRoots(context.Background()); !errors.As(err, &rerr) {
+				t.Errorf("expected root error, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package putiosync
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/cenkalti/log"
+	"github.com/putdotio/putio-sync/v2/internal/inode"
+	"go.etcd.io/bbolt"
+)
+
+// rootMarkerName is the file written in local dir on first sync.
+// It contains the ID of the sync pair, so a different or an empty dir is not mistaken for the synced one.
+const rootMarkerName = ".putio-sync-root"
+
+const metaLocalRoot = "localRoot"
+
+// localRoot is the identity of the local dir of a sync pair that is saved in database on first sync.
+type localRoot struct {
+	// Random ID that is written into the marker file.
+	ID string
+	// Device that contains the dir, changes if another drive is mounted at the path.
+	Device uint64
+}
+
+// rootError is returned when the root of a sync pair is not the one that is synced before.
+// Sync of the pair is stopped until the user fixes it or resets the root.
+type rootError struct {
+	reason string
+}
+
+func (e *rootError) Error() string {
+	return e.reason + `, check the dir and run "putio-sync reset-root" if this is intended`
+}
+
+// ensureLocalRoot creates the local dir and its marker on first sync.
+// On later syncs, it checks that the dir is the same one.
+func ensureLocalRoot() error {
+	var root localRoot
+	found, err := readMeta(metaLocalRoot, &root)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return initLocalRoot()
+	}
+	_, err = os.Stat(localPath)
+	if os.IsNotExist(err) {
+		return &rootError{fmt.Sprintf("local dir %q does not exist, is the drive mounted?", localPath)}
+	}
+	if err != nil {
+		return err
+	}
+	id, err := readRootMarker()
+	if os.IsNotExist(err) {
+		return &rootError{fmt.Sprintf("marker file %q is missing in local dir %q", rootMarkerName, localPath)}
+	}
+	if err != nil {
+		return err
+	}
+	if id != root.ID {
+		return &rootError{fmt.Sprintf("local dir %q belongs to another sync pair", localPath)}
+	}
+	dev, err := inode.Device(localPath)
+	if err != nil {
+		return err
+	}
+	if dev != root.Device {
+		return &rootError{fmt.Sprintf("device of local dir %q has changed", localPath)}
+	}
+	return nil
+}
+
+func initLocalRoot() error {
+	_, err := os.Stat(localPath)
+	if os.IsNotExist(err) {
+		// Files synced by a previous version must not be deleted because the dir is missing.
+		synced, err := hasStates()
+		if err != nil {
+			return err
+		}
+		if synced {
+			return &rootError{fmt.Sprintf("local dir %q does not exist, is the drive mounted?", localPath)}
+		}
+		if cfg.DryRun {
+			// Dir is created on first sync, plan it as empty.
+			localRootMissing = true
+			return nil
+		}
+	} else if err != nil {
+		return err
+	}
+	if cfg.DryRun {
+		return nil
+	}
+	err = os.MkdirAll(localPath, 0777)
+	if err != nil {
+		return err
+	}
+	// Keep the ID in the marker if the dir is already marked, e.g. after the root is reset.
+	id, err := readRootMarker()
+	if os.IsNotExist(err) {
+		b := make([]byte, 16)
+		_, err = rand.Read(b)
+		if err != nil {
+			return err
+		}
+		id = hex.EncodeToString(b)
+		err = os.WriteFile(filepath.Join(localPath, rootMarkerName), []byte(id+"\n"), 0666)
+	}
+	if err != nil {
+		return err
+	}
+	dev, err := inode.Device(localPath)
+	if err != nil {
+		return err
+	}
+	log.Infof("Local dir %q is marked with id %s", localPath, id)
+	return writeMeta(metaLocalRoot, localRoot{ID: id, Device: dev})
+}
+
+func readRootMarker() (string, error) {
+	b, err := os.ReadFile(filepath.Join(localPath, rootMarkerName))
+	return strings.TrimSpace(string(b)), err
+}
+
+// hasStates reports whether any file of the current sync pair is synced before.
+func hasStates() (bool, error) {
+	var found bool
+	err := db.View(func(tx *bbolt.Tx) error {
+		k, _ := pairBucket(tx, bucketFiles).Cursor().First()
+		found = k != nil
+		return nil
+	})
+	return found, err
+}
+
+// pendingResets contains the names of sync pairs that their roots are going to be reset before next sync.
+var pendingResets = struct {
+	m     sync.Mutex
+	names map[string]struct{}
+}{names: make(map[string]struct{})}
+
+func requestRootReset(name string) {
+	pendingResets.m.Lock()
+	pendingResets.names[name] = struct{}{}
+	pendingResets.m.Unlock()
+}
+
+func takeRootReset(name string) bool {
+	pendingResets.m.Lock()
+	defer pendingResets.m.Unlock()
+	_, ok := pendingResets.names[name]
+	delete(pendingResets.names, name)
+	return ok
+}
+
+// resetRoot forgets the roots of the current sync pair and everything synced so far.
+// Next sync is done as if it is the first one, so files missing on one side are not deleted on the other side.
+// Selected folders are kept.
+func resetRoot() error {
+	log.Warningf("Resetting roots of sync pair %q", folder.Name)
+	delete(remoteTrees, folder.Name)
+	return db.Update(func(tx *bbolt.Tx) error {
+		pair := tx.Bucket(bucketPairs).Bucket([]byte(folder.Name))
+		for _, name := range [][]byte{bucketFiles, bucketConflicts, bucketHashes, bucketFailures} {
+			err := pair.DeleteBucket(name)
+			if err != nil {
+				return err
+			}
+			_, err = pair.CreateBucket(name)
+			if err != nil {
+				return err
+			}
+		}
+		meta := pair.Bucket(bucketMeta)
+		err := meta.Delete([]byte(metaLocalRoot))
+		if err != nil {
+			return err
+		}
+		return meta.Delete([]byte(metaRemoteRoot))
+	})
+}
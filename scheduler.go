@@ -0,0 +1,194 @@
+package putiosync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/cenkalti/log"
+)
+
+const (
+	defaultUploadConcurrency   = 2
+	defaultDownloadConcurrency = 2
+	// Jobs other than transfers are fast, they are limited only to avoid flooding the API.
+	otherConcurrency = 4
+)
+
+// jobQueue is the queue that limits the concurrency of a job.
+type jobQueue int
+
+const (
+	queueOther jobQueue = iota
+	queueUpload
+	queueDownload
+)
+
+func queueOf(job iJob) jobQueue {
+	switch j := job.(type) {
+	case *uploadJob, *overwriteRemoteJob:
+		return queueUpload
+	case *downloadJob, *overwriteLocalJob:
+		return queueDownload
+	case *keepBothJob:
+		if j.renameRemote {
+			return queueUpload
+		}
+		return queueDownload
+	default:
+		return queueOther
+	}
+}
+
+// jobDependencies returns the indexes of jobs that must be finished before each job can start.
+// A job depends on previous jobs that change the same path, a parent or a child of its paths.
+// This keeps the order of reconciliation where it matters, e.g. files are moved out of a folder before it is deleted.
+func jobDependencies(jobs []iJob) [][]int {
+	deps := make([][]int, len(jobs))
+	// Jobs changing the path.
+	byPath := make(map[string][]int)
+	// Jobs changing a path inside the folder.
+	byDir := make(map[string][]int)
+	for i, job := range jobs {
+		seen := make(map[int]struct{})
+		add := func(l []int) {
+			for _, j := range l {
+				if _, ok := seen[j]; !ok {
+					seen[j] = struct{}{}
+					deps[i] = append(deps[i], j)
+				}
+			}
+		}
+		for _, p := range job.Paths() {
+			add(byPath[p])
+			add(byDir[p])
+			for dir := path.Dir(p); dir != "." && dir != "/"; dir = path.Dir(dir) {
+				add(byPath[dir])
+			}
+		}
+		for _, p := range job.Paths() {
+			byPath[p] = append(byPath[p], i)
+			for dir := path.Dir(p); dir != "." && dir != "/"; dir = path.Dir(dir) {
+				byDir[dir] = append(byDir[dir], i)
+			}
+		}
+	}
+	return deps
+}
+
+// runJobs runs jobs concurrently while respecting their dependencies.
+// A failing job does not stop other jobs, but jobs depending on it are skipped.
+// Returns errors of all failed jobs.
+func runJobs(ctx context.Context, jobs []iJob) error {
+	infos := trackJobs(jobs)
+	defer untrackJobs(infos)
+	deps := jobDependencies(jobs)
+	waiting := make([]int, len(jobs))
+	dependents := make([][]int, len(jobs))
+	for i, l := range deps {
+		waiting[i] = len(l)
+		for _, j := range l {
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+
+	limits := map[jobQueue]int{
+		queueUpload:   cfg.UploadConcurrency,
+		queueDownload: cfg.DownloadConcurrency,
+		queueOther:    otherConcurrency,
+	}
+	if limits[queueUpload] <= 0 {
+		limits[queueUpload] = defaultUploadConcurrency
+	}
+	if limits[queueDownload] <= 0 {
+		limits[queueDownload] = defaultDownloadConcurrency
+	}
+	ready := make(map[jobQueue][]int)
+	running := make(map[jobQueue]int)
+	for i := range jobs {
+		if waiting[i] == 0 {
+			q := queueOf(jobs[i])
+			ready[q] = append(ready[q], i)
+		}
+	}
+
+	type result struct {
+		index int
+		err   error
+	}
+	resultC := make(chan result)
+	var errs []error
+	var active, finished int
+	// Error of the failed dependency of a job.
+	blocked := make([]error, len(jobs))
+	// release is called when the job is finished. Cause is not nil if the job is failed or skipped.
+	var release func(i int, cause error)
+	release = func(i int, cause error) {
+		for _, j := range dependents[i] {
+			if cause != nil && blocked[j] == nil {
+				blocked[j] = cause
+			}
+			waiting[j]--
+			if waiting[j] > 0 {
+				continue
+			}
+			if blocked[j] != nil {
+				finished++
+				log.Warningf("Skipping job %q: %s", jobs[j].String(), blocked[j].Error())
+				jobFinished(infos[j], jobSkipped, blocked[j])
+				recordJob(infos[j])
+				release(j, blocked[j])
+				continue
+			}
+			q := queueOf(jobs[j])
+			ready[q] = append(ready[q], j)
+		}
+	}
+	for finished < len(jobs) {
+		// Start ready jobs up to the limit of their queue.
+		if ctx.Err() == nil {
+			for q, l := range ready {
+				for len(l) > 0 && running[q] < limits[q] {
+					i := l[0]
+					l = l[1:]
+					running[q]++
+					active++
+					syncStatus = jobs[i].String()
+					log.Infoln(syncStatus)
+					jobStarted(infos[i])
+					go func(i int) {
+						resultC <- result{index: i, err: jobs[i].Run(withJobInfo(ctx, infos[i]))}
+					}(i)
+				}
+				ready[q] = l
+			}
+		}
+		if active == 0 {
+			// Context is cancelled, remaining jobs are not started.
+			errs = append(errs, ctx.Err())
+			break
+		}
+		res := <-resultC
+		active--
+		finished++
+		running[queueOf(jobs[res.index])]--
+		jobFinished(infos[res.index], "", res.err)
+		recordJob(infos[res.index])
+		// Jobs stopped by pause or shutdown are not counted as failed attempts.
+		if ctx.Err() == nil {
+			if err := updateFailure(infos[res.index]); err != nil {
+				log.Errorln("cannot save failure:", err.Error())
+			}
+		}
+		var cause error
+		if res.err != nil {
+			err := fmt.Errorf("%s: %w", jobs[res.index].String(), res.err)
+			log.Errorln(err.Error())
+			errs = append(errs, err)
+			cause = fmt.Errorf("depends on failed job %q", jobs[res.index].String())
+		}
+		release(res.index, cause)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,113 @@
+package putiosync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type fakeJob struct {
+	paths []string
+	err   error
+	run   func()
+}
+
+func (j *fakeJob) Run(context.Context) error {
+	if j.run != nil {
+		j.run()
+	}
+	return j.err
+}
+
+func (j *fakeJob) String() string {
+	return "fake job " + j.paths[0]
+}
+
+func (j *fakeJob) Paths() []string {
+	return j.paths
+}
+
+func TestJobDependencies(t *testing.T) {
+	jobs := []iJob{
+		&fakeJob{paths: []string{"a/x", "b/x"}}, // move
+		&fakeJob{paths: []string{"a/y"}},
+		&fakeJob{paths: []string{"c"}},
+		&fakeJob{paths: []string{"a"}}, // folder delete
+		&fakeJob{paths: []string{"b/x/z"}},
+		&fakeJob{paths: []string{"ab"}},
+	}
+	expected := [][]int{nil, nil, nil, {0, 1}, {0}, nil}
+	deps := jobDependencies(jobs)
+	if !reflect.DeepEqual(deps, expected) {
+		t.Errorf("expected %v, got %v", expected, deps)
+	}
+}
+
+func TestRunJobs(t *testing.T) {
+	openTestDB(t)
+	folder = FolderConfig{Name: "test"}
+	defer func() { folder = FolderConfig{} }()
+	if err := createPairBuckets(); err != nil {
+		t.Fatal(err)
+	}
+	var m sync.Mutex
+	var ran []string
+	record := func(name string) func() {
+		return func() {
+			m.Lock()
+			ran = append(ran, name)
+			m.Unlock()
+		}
+	}
+	errFail := errors.New("fail")
+	jobs := []iJob{
+		&fakeJob{paths: []string{"a/x"}, err: errFail, run: record("a/x")},
+		&fakeJob{paths: []string{"b"}, run: record("b")},
+		&fakeJob{paths: []string{"a"}, run: record("a")},
+		&fakeJob{paths: []string{"a/x/y"}, run: record("a/x/y")},
+	}
+	err := runJobs(context.Background(), jobs)
+	if !errors.Is(err, errFail) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(ran) != 2 {
+		t.Errorf("dependents of failed job must be skipped, ran: %v", ran)
+	}
+	failures, err := readFailures()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 1 || failures["a/x"].Attempts != 1 || failures["a/x"].LastError != errFail.Error() {
+		t.Errorf("only the failed job must be recorded, got: %+v", failures)
+	}
+}
+
+func TestListJobsLimit(t *testing.T) {
+	openTestDB(t)
+	folder = FolderConfig{Name: "test"}
+	defer func() { folder = FolderConfig{} }()
+	if err := createPairBuckets(); err != nil {
+		t.Fatal(err)
+	}
+	jobs := make([]iJob, maxListedQueuedJobs+50)
+	for i := range jobs {
+		jobs[i] = &fakeJob{paths: []string{fmt.Sprintf("file%d", i)}}
+	}
+	infos := trackJobs(jobs)
+	defer untrackJobs(infos)
+	var queued int
+	for _, info := range listJobs() {
+		if info.State == jobQueued {
+			queued++
+		}
+	}
+	if queued != maxListedQueuedJobs {
+		t.Errorf("expected %d queued jobs, got %d", maxListedQueuedJobs, queued)
+	}
+	if n := queuedTotals().Jobs; n != len(jobs) {
+		t.Errorf("expected %d queued jobs in totals, got %d", len(jobs), n)
+	}
+}
@@ -0,0 +1,105 @@
+package putiosync
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+
+	"github.com/putdotio/putio-sync/v2/internal/walker"
+)
+
+const (
+	// Local copies of excluded folders are kept but they are not synced anymore.
+	excludeActionKeep = "keep"
+	// Local copies of excluded folders are removed.
+	excludeActionRemove = "remove"
+)
+
+const metaInclude = "include"
+
+// includeOverride is the list of selected folders that is set at runtime.
+// It takes precedence over the list in config until the list in config is changed.
+type includeOverride struct {
+	Include       []string
+	ExcludeAction string
+	// Value of Include in config at the time override is set.
+	Config []string
+}
+
+// selectedFolders returns the list of folders to be synced in the sync pair and the action for excluded folders.
+func selectedFolders(f FolderConfig) ([]string, string, error) {
+	var o includeOverride
+	found, err := readPairMeta(f.Name, metaInclude, &o)
+	if err != nil {
+		return nil, "", err
+	}
+	if found && reflect.DeepEqual(o.Config, f.Include) {
+		return o.Include, o.ExcludeAction, nil
+	}
+	return f.Include, f.ExcludeAction, nil
+}
+
+// setSelectedFolders changes the list of folders to be synced in the sync pair.
+func setSelectedFolders(f FolderConfig, include []string, excludeAction string) error {
+	if excludeAction == "" {
+		excludeAction = f.ExcludeAction
+	}
+	if !validExcludeAction(excludeAction) {
+		return fmt.Errorf("invalid exclude action: %q", excludeAction)
+	}
+	return writePairMeta(f.Name, metaInclude, includeOverride{
+		Include:       include,
+		ExcludeAction: excludeAction,
+		Config:        f.Include,
+	})
+}
+
+// filterOutExcluded removes the files outside of selected folders from syncFiles.
+// Excluded files are treated as not present on both sides, so they are never deleted by reconciliation.
+// Returns jobs for cleaning up the states of previously synced files in excluded folders.
+// Local copies are removed only in modes that change local files.
+func filterOutExcluded(syncFiles map[string]*syncFile, include walker.Selection, excludeAction string, mode syncMode) []iJob {
+	excluded := make(map[string][]stateType)
+	dirs := make(map[string]bool)
+	var roots []string
+	for relpath, sf := range syncFiles {
+		if include.Contains(relpath) || include.IsParent(relpath) {
+			continue
+		}
+		delete(syncFiles, relpath)
+		if sf.state == nil {
+			continue
+		}
+		// Group states by the top-most excluded folder.
+		root := relpath
+		for dir := path.Dir(relpath); dir != "."; dir = path.Dir(dir) {
+			if include.IsParent(dir) {
+				break
+			}
+			root = dir
+		}
+		if _, ok := excluded[root]; !ok {
+			roots = append(roots, root)
+		}
+		excluded[root] = append(excluded[root], *sf.state)
+		if relpath != root || sf.state.IsDir {
+			dirs[root] = true
+		}
+	}
+	jobs := make([]iJob, 0, len(roots))
+	sort.Strings(roots)
+	for _, root := range roots {
+		jobs = append(jobs, &excludeFolderJob{
+			relpath: root,
+			isDir:   dirs[root],
+			remove:  excludeAction == excludeActionRemove && mode.downloads(),
+			states:  excluded[root],
+		})
+	}
+	return jobs
+}
+
+func validExcludeAction(action string) bool {
+	return action == excludeActionKeep || action == excludeActionRemove
+}
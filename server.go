@@ -1,64 +1,617 @@
 package putiosync
 
 import (
+	"bytes"
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"mime"
 	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/cenkalti/log"
+	"github.com/putdotio/putio-sync/v2/internal/api"
 )
 
 const (
 	serverReadTimeout     = 5 * time.Second
 	serverWriteTimeout    = 10 * time.Second
 	serverShutdownTimeout = 5 * time.Second
+	// How often status and jobs are checked for changes in events stream.
+	eventsInterval = time.Second
 )
 
 type httpServer struct {
 	srv *http.Server
+	// Cancels requests that stream responses, so they do not block shutdown.
+	cancel context.CancelFunc
 }
 
 func newServer(addr string) *httpServer {
 	m := http.NewServeMux()
-	m.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte("putio-sync")) })
+	m.Handle("/", dashboardHandler())
 	m.HandleFunc("/syncing", func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte(fmt.Sprintf("%v", syncing))) })
-	m.HandleFunc("/trigger", func(w http.ResponseWriter, r *http.Request) { triggerSync() })
-	m.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
-		b, _ := json.Marshal(map[string]string{"status": syncStatus})
-		_, _ = w.Write(b)
+	m.HandleFunc("/trigger", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		triggerSync()
+	})
+	m.HandleFunc("/status", handleStatus)
+	m.HandleFunc("/pause", handlePause(true))
+	m.HandleFunc("/resume", handlePause(false))
+	m.HandleFunc("/jobs", handleJobs)
+	m.HandleFunc("/logs", handleLogs)
+	m.HandleFunc("/history", handleHistory)
+	m.HandleFunc("/events", handleEvents)
+	m.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeMetrics(w)
 	})
+	m.HandleFunc("/include", handleInclude)
+	m.HandleFunc("/conflicts", handleConflicts)
+	m.HandleFunc("/conflicts/resolve", handleResolveConflict)
+	m.HandleFunc("/limits", handleLimits)
+	m.HandleFunc("/confirm-deletes", handleConfirmDeletes)
+	m.HandleFunc("/reset-root", handleResetRoot)
+	m.HandleFunc("/retry", handleRetry)
+	ctx, cancel := context.WithCancel(context.Background())
 	s := &httpServer{
 		srv: &http.Server{
 			Addr:         addr,
-			Handler:      m,
+			Handler:      authHandler(csrfHandler(m)),
 			ReadTimeout:  serverReadTimeout,
 			WriteTimeout: serverWriteTimeout,
+			BaseContext:  func(net.Listener) context.Context { return ctx },
 		},
+		cancel: cancel,
 	}
 	return s
 }
 
+func currentStatus() api.Status {
+	paused, _ := isPaused()
+	quarantined, err := listQuarantined()
+	if err != nil {
+		log.Errorln("cannot list quarantined files:", err.Error())
+	}
+	return api.Status{Status: syncStatus, Syncing: syncing, Paused: paused, Queued: queuedTotals(), Quarantined: quarantined}
+}
+
+func handleStatus(w http.ResponseWriter, r *http.Request) {
+	b, _ := json.Marshal(currentStatus())
+	_, _ = w.Write(b)
+}
+
+func handlePause(paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		setPaused(paused)
+	}
+}
+
+func handleJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	b, _ := json.Marshal(listJobs())
+	_, _ = w.Write(b)
+}
+
+// handleEvents streams changes in status, jobs and logs as Server-Sent Events.
+// Event names are "status", "jobs" and "log". Data is in the same format as the responses of their endpoints.
+// Status and jobs are sent when they change, log messages are sent one by one.
+// Jobs list is limited like /jobs, status contains the totals of all queued jobs.
+func handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rc := http.NewResponseController(w)
+	err := rc.SetWriteDeadline(time.Time{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	ticker := time.NewTicker(eventsInterval)
+	defer ticker.Stop()
+	// Only new log messages are sent.
+	var logSeq int64
+	var logsChanged <-chan struct{}
+	if logs != nil {
+		var l []api.LogEntry
+		l, logsChanged = logs.since(0)
+		if len(l) > 0 {
+			logSeq = l[len(l)-1].Seq
+		}
+	}
+	last := make(map[string][]byte)
+	send := func(event string, v interface{}) error {
+		b, _ := json.Marshal(v)
+		if bytes.Equal(last[event], b) {
+			return nil
+		}
+		if event != "log" {
+			last[event] = b
+		}
+		_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+		return err
+	}
+	for {
+		if err = send("status", currentStatus()); err != nil {
+			return
+		}
+		if err = send("jobs", listJobs()); err != nil {
+			return
+		}
+		if logs != nil {
+			var l []api.LogEntry
+			l, logsChanged = logs.since(logSeq)
+			for _, e := range l {
+				if err = send("log", e); err != nil {
+					return
+				}
+				logSeq = e.Seq
+			}
+		}
+		if err = rc.Flush(); err != nil {
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-logsChanged:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// handleLogs returns recent log messages after the sequence number in "since" parameter.
+// If "follow" parameter is set, new messages are streamed as JSON lines until the client disconnects.
+func handleLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var since int64
+	if s := r.URL.Query().Get("since"); s != "" {
+		var err error
+		since, err = strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if logs == nil {
+		http.Error(w, "logs are not available", http.StatusServiceUnavailable)
+		return
+	}
+	l, changed := logs.since(since)
+	if r.URL.Query().Get("follow") == "" {
+		if l == nil {
+			l = []api.LogEntry{}
+		}
+		b, _ := json.Marshal(l)
+		_, _ = w.Write(b)
+		return
+	}
+	rc := http.NewResponseController(w)
+	err := rc.SetWriteDeadline(time.Time{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	for {
+		for _, e := range l {
+			if err = enc.Encode(e); err != nil {
+				return
+			}
+			since = e.Seq
+		}
+		if err = rc.Flush(); err != nil {
+			return
+		}
+		select {
+		case <-changed:
+			l, changed = logs.since(since)
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// handleHistory returns the records of finished jobs, newest first.
+// "path" selects the records of a file or folder and "since" selects the records after a time.
+// Since can be a time in RFC 3339 format or a duration before now, such as "24h".
+func handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := historyQuery{Path: r.URL.Query().Get("path")}
+	if s := r.URL.Query().Get("since"); s != "" {
+		var err error
+		q.Since, err = parseSince(s, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	l, err := listHistory(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	b, _ := json.Marshal(l)
+	_, _ = w.Write(b)
+}
+
+// includeRequest is the body of the request for changing selected folders of a sync pair.
+type includeRequest struct {
+	Folder        string   `json:"folder"`
+	Include       []string `json:"include"`
+	ExcludeAction string   `json:"excludeAction,omitempty"`
+}
+
+func handleInclude(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		f, err := findFolder(r.URL.Query().Get("folder"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		include, excludeAction, err := selectedFolders(f)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		b, _ := json.Marshal(includeRequest{Folder: f.Name, Include: include, ExcludeAction: excludeAction})
+		_, _ = w.Write(b)
+	case http.MethodPost:
+		var req includeRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f, err := findFolder(req.Folder)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		err = setSelectedFolders(f, req.Include, req.ExcludeAction)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		triggerSync()
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleConflicts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	l, err := listConflicts()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	b, _ := json.Marshal(l)
+	_, _ = w.Write(b)
+}
+
+// resolveRequest is the body of the request for resolving a conflict.
+type resolveRequest struct {
+	Folder string `json:"folder"`
+	Path   string `json:"path"`
+	// One of keep-local, keep-remote or keep-both.
+	Action string `json:"action"`
+}
+
+func handleResolveConflict(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req resolveRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f, err := findFolder(req.Folder)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	err = setConflictResolution(f, req.Path, req.Action)
+	if errors.Is(err, errConflictNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	triggerSync()
+}
+
+func handleLimits(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req bandwidthLimits
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = setBandwidthLimits(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	b, _ := json.Marshal(getBandwidthLimits())
+	_, _ = w.Write(b)
+}
+
+// confirmRequest is the body of the request for confirming blocked deletions.
+type confirmRequest struct {
+	Folder string `json:"folder"`
+}
+
+func handleConfirmDeletes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req confirmRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f, err := findFolder(req.Folder)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	err = confirmDeletes(f.Name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	triggerSync()
+}
+
+func handleResetRoot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req confirmRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f, err := findFolder(req.Folder)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	requestRootReset(f.Name)
+	triggerSync()
+}
+
+// retryRequest is the body of the request for retrying failed files.
+type retryRequest struct {
+	Folder string `json:"folder"`
+	// All failed files of the folder are retried if empty.
+	Path string `json:"path,omitempty"`
+}
+
+func handleRetry(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req retryRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f, err := findFolder(req.Folder)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	err = retryFailed(f.Name, req.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	triggerSync()
+}
+
+// findFolder returns the sync pair with the given name.
+// Name can be empty if there is only one sync pair.
+func findFolder(name string) (FolderConfig, error) {
+	if name == "" && len(cfg.Folders) == 1 {
+		return cfg.Folders[0], nil
+	}
+	for _, f := range cfg.Folders {
+		if f.Name == name {
+			return f, nil
+		}
+	}
+	return FolderConfig{}, fmt.Errorf("folder not found: %q", name)
+}
+
 func (s *httpServer) Close() {
 	s.srv.Close()
 }
 
 func (s *httpServer) Start() {
-	l, err := net.Listen("tcp4", s.srv.Addr)
+	l, err := listen(s.srv.Addr)
 	if err != nil {
 		log.Fatal(err)
 	}
 	log.Infoln("Server is listening on", l.Addr().String())
+	if cfg.ServerToken == "" && cfg.ServerUsername == "" && !isLocalListener(l) {
+		log.Warningln("Server is reachable from network without authentication, set ServerToken or ServerUsername in config")
+	}
 	go func() {
-		if err := s.srv.Serve(l); err != http.ErrServerClosed {
+		if cfg.ServerTLSCert != "" {
+			err = s.srv.ServeTLS(l, cfg.ServerTLSCert, cfg.ServerTLSKey)
+		} else {
+			err = s.srv.Serve(l)
+		}
+		if err != http.ErrServerClosed {
 			log.Fatal(err)
 		}
 	}()
 }
 
+// listen listens on a TCP address or on a Unix socket if addr is prefixed with "unix:".
+// TCP addresses without a host, such as ":3000", listen on both IPv4 and IPv6.
+func listen(addr string) (net.Listener, error) {
+	if !strings.HasPrefix(addr, unixPrefix) {
+		return net.Listen("tcp", addr)
+	}
+	path := strings.TrimPrefix(addr, unixPrefix)
+	// Remove the socket left from previous run.
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		err = os.Remove(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	mode, err := parseSocketMode(cfg.ServerSocketMode)
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+	err = os.Chmod(path, mode)
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func isLocalListener(l net.Listener) bool {
+	switch a := l.Addr().(type) {
+	case *net.UnixAddr:
+		return true
+	case *net.TCPAddr:
+		return a.IP.IsLoopback()
+	default:
+		return false
+	}
+}
+
+// authHandler rejects requests without the credentials set in config.
+// A request is accepted if it has the bearer token or the basic auth credentials.
+func authHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (cfg.ServerToken == "" && cfg.ServerUsername == "") || isDashboardPath(r.URL.Path) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if cfg.ServerToken != "" {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if ok && secureCompare(token, cfg.ServerToken) {
+				h.ServeHTTP(w, r)
+				return
+			}
+		}
+		if cfg.ServerUsername != "" {
+			username, password, ok := r.BasicAuth()
+			if ok && secureCompare(username, cfg.ServerUsername) && secureCompare(password, cfg.ServerPassword) {
+				h.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Basic realm="putio-sync"`)
+		}
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	})
+}
+
+// csrfHandler rejects requests that change something if they may be sent by a page on another site.
+// Browsers send simple requests of other sites without asking the server first, but they cannot have JSON content type.
+// Cached basic auth credentials are sent with them, and a server without auth needs no credentials at all.
+func csrfHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if isCrossOrigin(r) {
+			http.Error(w, "cross-origin request", http.StatusForbidden)
+			return
+		}
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType != "application/json" {
+			http.Error(w, "content type must be application/json", http.StatusUnsupportedMediaType)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// isCrossOrigin reports whether the request is sent by a page on another site.
+// Requests without Origin and Sec-Fetch-Site headers are not sent by browsers, so they are allowed.
+func isCrossOrigin(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "", "same-origin", "none":
+	default:
+		return true
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	u, err := url.Parse(origin)
+	return err != nil || u.Host != r.Host
+}
+
+func secureCompare(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
 func (s *httpServer) Shutdown() error {
+	s.cancel()
 	ctx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
 	defer cancel()
 	return s.srv.Shutdown(ctx)
@@ -0,0 +1,103 @@
+package putiosync
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServerAuth(t *testing.T) {
+	openTestDB(t)
+	cfg = Config{ServerToken: "secret", ServerUsername: "user", ServerPassword: "pass"}
+	defer func() { cfg = Config{} }()
+	s := newServer("")
+	token := func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") }
+	basic := func(r *http.Request) { r.SetBasicAuth("user", "pass") }
+	none := func(r *http.Request) {}
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		auth   func(r *http.Request)
+		code   int
+	}{
+		{"no credentials", http.MethodGet, "/status", none, http.StatusUnauthorized},
+		{"wrong token", http.MethodGet, "/status", func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") }, http.StatusUnauthorized},
+		{"token", http.MethodGet, "/status", token, http.StatusOK},
+		{"wrong password", http.MethodGet, "/status", func(r *http.Request) { r.SetBasicAuth("user", "wrong") }, http.StatusUnauthorized},
+		{"basic auth", http.MethodGet, "/status", basic, http.StatusOK},
+		{"mutating with GET", http.MethodGet, "/trigger", basic, http.StatusMethodNotAllowed},
+		{"dashboard", http.MethodGet, "/", none, http.StatusOK},
+		{"dashboard assets", http.MethodGet, "/assets/app.js", none, http.StatusOK},
+		{"jobs", http.MethodGet, "/jobs", none, http.StatusUnauthorized},
+		{"history", http.MethodGet, "/history", none, http.StatusUnauthorized},
+		{"conflicts", http.MethodGet, "/conflicts", none, http.StatusUnauthorized},
+		{"metrics", http.MethodGet, "/metrics", none, http.StatusUnauthorized},
+		{"trigger", http.MethodPost, "/trigger", none, http.StatusUnauthorized},
+		{"include", http.MethodPost, "/include", none, http.StatusUnauthorized},
+		{"reset root", http.MethodPost, "/reset-root", none, http.StatusUnauthorized},
+		{"confirm deletes", http.MethodPost, "/confirm-deletes", none, http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, c.path, nil)
+		r.Header.Set("Content-Type", "application/json")
+		c.auth(r)
+		w := httptest.NewRecorder()
+		s.srv.Handler.ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("%s: expected %d, got %d", c.name, c.code, w.Code)
+		}
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	cfg = Config{ServerToken: "secret"}
+	defer func() { cfg = Config{} }()
+	s := newServer("")
+	cases := []struct {
+		path string
+		code int
+	}{
+		{"/", http.StatusOK},
+		{"/assets/app.js", http.StatusOK},
+		{"/assets/missing.js", http.StatusNotFound},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		s.srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if w.Code != c.code {
+			t.Errorf("%s: expected %d, got %d", c.path, c.code, w.Code)
+		}
+	}
+}
+
+func TestServerCSRF(t *testing.T) {
+	s := newServer("")
+	cases := []struct {
+		name   string
+		header map[string]string
+		code   int
+	}{
+		{"json", map[string]string{"Content-Type": "application/json"}, http.StatusOK},
+		{"same origin", map[string]string{"Content-Type": "application/json", "Origin": "http://example.com", "Sec-Fetch-Site": "same-origin"}, http.StatusOK},
+		{"no content type", map[string]string{}, http.StatusUnsupportedMediaType},
+		{"text/plain", map[string]string{"Content-Type": "text/plain"}, http.StatusUnsupportedMediaType},
+		{"form", map[string]string{"Content-Type": "application/x-www-form-urlencoded"}, http.StatusUnsupportedMediaType},
+		{"other origin", map[string]string{"Content-Type": "application/json", "Origin": "http://evil.com"}, http.StatusForbidden},
+		{"null origin", map[string]string{"Content-Type": "application/json", "Origin": "null"}, http.StatusForbidden},
+		{"cross-site", map[string]string{"Content-Type": "application/json", "Sec-Fetch-Site": "cross-site"}, http.StatusForbidden},
+		{"same-site", map[string]string{"Content-Type": "application/json", "Sec-Fetch-Site": "same-site"}, http.StatusForbidden},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodPost, "/trigger", strings.NewReader("{}"))
+		for k, v := range c.header {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		s.srv.Handler.ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("%s: expected %d, got %d", c.name, c.code, w.Code)
+		}
+	}
+}
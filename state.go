@@ -2,7 +2,9 @@ package putiosync
 
 import (
 	"encoding/json"
+	"errors"
 
+	"github.com/cenkalti/log"
 	"go.etcd.io/bbolt"
 )
 
@@ -14,7 +16,14 @@ const (
 	statusUploading   = "uploading"
 )
 
-var bucketFiles = []byte("files")
+var (
+	// bucketPairs contains a nested bucket for each sync pair.
+	bucketPairs = []byte("pairs")
+	// bucketFiles contains file states of a sync pair.
+	bucketFiles = []byte("files")
+	// bucketMeta contains information about a sync pair other than file states.
+	bucketMeta = []byte("meta")
+)
 
 // State stores information about syncing files and folders.
 type stateType struct {
@@ -31,43 +40,207 @@ type stateType struct {
 	relpath          string
 }
 
-func readAllStates() ([]stateType, error) {
-	var l []stateType
-	err := db.Update(func(tx *bbolt.Tx) error {
-		remove := make([][]byte, 0)
-		b := tx.Bucket(bucketFiles)
-		err := b.ForEach(func(key, val []byte) error {
-			var s stateType
-			err := json.Unmarshal(val, &s)
+// createPairBuckets creates the buckets of the current sync pair if they don't exist.
+func createPairBuckets() error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		pairs, err := tx.CreateBucketIfNotExists(bucketPairs)
+		if err != nil {
+			return err
+		}
+		pair, err := pairs.CreateBucketIfNotExists([]byte(folder.Name))
+		if err != nil {
+			return err
+		}
+		for _, name := range [][]byte{bucketFiles, bucketMeta, bucketConflicts, bucketHashes, bucketFailures} {
+			_, err = pair.CreateBucketIfNotExists(name)
 			if err != nil {
 				return err
 			}
-			if s.LocalRoot != cfg.LocalDir {
-				remove = append(remove, key)
-			} else {
-				s.relpath = string(key)
-				l = append(l, s)
-			}
+		}
+		// Checksum failures were kept in a separate bucket before they are recorded in failures bucket.
+		err = pair.DeleteBucket([]byte("integrity"))
+		if err != nil && !errors.Is(err, bbolt.ErrBucketNotFound) {
+			return err
+		}
+		return nil
+	})
+}
+
+// pairBucket returns the bucket with the given name for the current sync pair.
+func pairBucket(tx *bbolt.Tx, name []byte) *bbolt.Bucket {
+	return tx.Bucket(bucketPairs).Bucket([]byte(folder.Name)).Bucket(name)
+}
+
+// readMeta reads the value at key from meta bucket of the current sync pair.
+// Returns false if there is no value at key.
+func readMeta(key string, v interface{}) (bool, error) {
+	return readPairMeta(folder.Name, key, v)
+}
+
+// writeMeta writes the value to meta bucket of the current sync pair.
+func writeMeta(key string, v interface{}) error {
+	return writePairMeta(folder.Name, key, v)
+}
+
+// readPairMeta reads the value at key from meta bucket of the sync pair with the given name.
+// Returns false if there is no value at key.
+func readPairMeta(name, key string, v interface{}) (bool, error) {
+	var val []byte
+	err := db.View(func(tx *bbolt.Tx) error {
+		pairs := tx.Bucket(bucketPairs)
+		if pairs == nil {
 			return nil
-		})
+		}
+		pair := pairs.Bucket([]byte(name))
+		if pair == nil {
+			return nil
+		}
+		meta := pair.Bucket(bucketMeta)
+		if meta == nil {
+			return nil
+		}
+		val = meta.Get([]byte(key))
+		if val == nil {
+			return nil
+		}
+		return json.Unmarshal(val, v)
+	})
+	return val != nil, err
+}
+
+// writePairMeta writes the value to meta bucket of the sync pair with the given name.
+func writePairMeta(name, key string, v interface{}) error {
+	val, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return db.Update(func(tx *bbolt.Tx) error {
+		pairs, err := tx.CreateBucketIfNotExists(bucketPairs)
 		if err != nil {
 			return err
 		}
-		for _, key := range remove {
-			err = b.Delete(key)
+		pair, err := pairs.CreateBucketIfNotExists([]byte(name))
+		if err != nil {
+			return err
+		}
+		b, err := pair.CreateBucketIfNotExists(bucketMeta)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), val)
+	})
+}
+
+// migrateStates moves states from the single bucket used by previous versions into the buckets of sync pairs.
+// States that do not belong to any configured pair are left in place.
+// Buckets of pairs that are removed from config are deleted.
+func migrateStates(folders []FolderConfig) error {
+	names := make(map[string]string, len(folders))
+	for _, f := range folders {
+		names[f.LocalDir] = f.Name
+	}
+	return db.Update(func(tx *bbolt.Tx) error {
+		pairs, err := tx.CreateBucketIfNotExists(bucketPairs)
+		if err != nil {
+			return err
+		}
+		old := tx.Bucket(bucketFiles)
+		if old != nil {
+			err = moveOldStates(old, pairs, names)
 			if err != nil {
 				return err
 			}
 		}
+		return removeStalePairs(pairs, folders)
+	})
+}
+
+func moveOldStates(old, pairs *bbolt.Bucket, names map[string]string) error {
+	remove := make([][]byte, 0)
+	err := old.ForEach(func(key, val []byte) error {
+		var s stateType
+		err := json.Unmarshal(val, &s)
+		if err != nil {
+			return err
+		}
+		name, ok := names[s.LocalRoot]
+		if !ok {
+			return nil
+		}
+		pair, err := pairs.CreateBucketIfNotExists([]byte(name))
+		if err != nil {
+			return err
+		}
+		b, err := pair.CreateBucketIfNotExists(bucketFiles)
+		if err != nil {
+			return err
+		}
+		remove = append(remove, key)
+		return b.Put(key, val)
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range remove {
+		err = old.Delete(key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeStalePairs deletes the buckets of sync pairs that are not in config anymore.
+// Otherwise the bucket of an unnamed pair would be kept forever after its local dir is changed.
+func removeStalePairs(pairs *bbolt.Bucket, folders []FolderConfig) error {
+	keep := make(map[string]struct{}, len(folders))
+	for _, f := range folders {
+		keep[f.Name] = struct{}{}
+	}
+	var remove [][]byte
+	err := pairs.ForEachBucket(func(name []byte) error {
+		if _, ok := keep[string(name)]; !ok {
+			remove = append(remove, name)
+		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+	for _, name := range remove {
+		log.Noticef("Removing sync state of %q because it is not in config anymore", name)
+		err = pairs.DeleteBucket(name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readAllStates() ([]stateType, error) {
+	var l []stateType
+	err := db.View(func(tx *bbolt.Tx) error {
+		b := pairBucket(tx, bucketFiles)
+		return b.ForEach(func(key, val []byte) error {
+			var s stateType
+			err := json.Unmarshal(val, &s)
+			if err != nil {
+				return err
+			}
+			// States are kept when the local dir of the pair is changed.
+			// A replaced dir is detected by ensureLocalRoot.
+			s.relpath = string(key)
+			l = append(l, s)
+			return nil
+		})
+	})
 	return l, err
 }
 
 func (s stateType) Write() error {
-	s.LocalRoot = cfg.LocalDir
+	s.LocalRoot = folder.LocalDir
 	return db.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket(bucketFiles)
+		b := pairBucket(tx, bucketFiles)
 		val, err := json.Marshal(s)
 		if err != nil {
 			return err
@@ -78,7 +251,7 @@ func (s stateType) Write() error {
 
 func (s stateType) Delete() error {
 	return db.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket(bucketFiles)
+		b := pairBucket(tx, bucketFiles)
 		return b.Delete([]byte(s.relpath))
 	})
 }
@@ -86,9 +259,9 @@ func (s stateType) Delete() error {
 // Move writes the state to database while changing the relpath key.
 // Move also deletes the record at old relpath.
 func (s *stateType) Move(target string) error {
-	s.LocalRoot = cfg.LocalDir
+	s.LocalRoot = folder.LocalDir
 	err := db.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket(bucketFiles)
+		b := pairBucket(tx, bucketFiles)
 		err := b.Delete([]byte(s.relpath))
 		if err != nil {
 			return err
@@ -0,0 +1,58 @@
+package putiosync
+
+import (
+	"testing"
+
+	"go.etcd.io/bbolt"
+)
+
+func TestStatesKeptAfterLocalDirChange(t *testing.T) {
+	openTestDB(t)
+	defer func() { folder = FolderConfig{} }()
+	folder = FolderConfig{Name: "media", LocalDir: "/mnt/old"}
+	err := createPairBuckets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = stateType{Status: statusSynced, relpath: "foo"}.Write()
+	if err != nil {
+		t.Fatal(err)
+	}
+	folder.LocalDir = "/mnt/new"
+	states, err := readAllStates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 1 || states[0].relpath != "foo" {
+		t.Errorf("states of named pair must be kept, got %+v", states)
+	}
+}
+
+func TestMigrateStatesRemovesStalePairs(t *testing.T) {
+	openTestDB(t)
+	defer func() { folder = FolderConfig{} }()
+	for _, name := range []string{"/mnt/old", "/mnt/new"} {
+		folder = FolderConfig{Name: name, LocalDir: name}
+		err := createPairBuckets()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := migrateStates([]FolderConfig{{Name: "/mnt/new", LocalDir: "/mnt/new"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	err = db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucketPairs).ForEachBucket(func(name []byte) error {
+			names = append(names, string(name))
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "/mnt/new" {
+		t.Errorf("only configured pairs must be kept, got %v", names)
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/adrg/xdg"
@@ -12,7 +15,9 @@ import (
 	"github.com/putdotio/go-putio"
 	"github.com/putdotio/putio-sync/v2/internal/auth"
 	"github.com/putdotio/putio-sync/v2/internal/dircache"
+	"github.com/putdotio/putio-sync/v2/internal/ignore"
 	"github.com/putdotio/putio-sync/v2/internal/tmpdir"
+	"github.com/putdotio/putio-sync/v2/internal/trash"
 	"github.com/putdotio/putio-sync/v2/internal/updates"
 	"github.com/putdotio/putio-sync/v2/internal/walker"
 	"github.com/putdotio/putio-sync/v2/internal/watcher"
@@ -30,16 +35,24 @@ var (
 	token          string
 	client         *putio.Client
 	notifier       = updates.NewNotifier("wss://socket.put.io/socket/sockjs/websocket", 10*time.Second, 5*time.Second)
-	watcherUpdates chan string
+	watcherUpdates = make(chan string, 1)
+	watchedDirs    = make(map[string]struct{})
+	folder         FolderConfig // sync pair that is being synced
 	localPath      string
 	remoteFolderID int64
 	dirCache       *dircache.DirCache
 	tempDirPath    string
+	hostname       string
 	syncing        bool
 	syncStatus     = "Starting sync..."
 	triggerSyncC   = make(chan struct{}, 1)
 )
 
+// dataFile returns the path of the file with the given name in the data dir of the program.
+func dataFile(name string) (string, error) {
+	return xdg.DataFile(filepath.Join("putio-sync", name))
+}
+
 func Sync(ctx context.Context, config Config) error {
 	if err := config.validate(); err != nil {
 		return err
@@ -47,7 +60,9 @@ func Sync(ctx context.Context, config Config) error {
 	if config.Debug {
 		log.SetLevel(log.DEBUG)
 	}
-	dbPath, err := xdg.DataFile(filepath.Join("putio-sync", "sync.db"))
+	logs = newLogBuffer(log.DefaultHandler)
+	log.DefaultLogger.SetHandler(logs)
+	dbPath, err := dataFile("sync.db")
 	if err != nil {
 		return err
 	}
@@ -58,10 +73,27 @@ func Sync(ctx context.Context, config Config) error {
 	}
 	defer db.Close()
 	cfg = config
-	err = db.Update(func(tx *bbolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists(bucketFiles)
+	cfg.Folders = config.folders()
+	if !cfg.DryRun {
+		err = openAuditJournal()
+		if err != nil {
+			return err
+		}
+		defer closeAuditJournal()
+	}
+	hostname, err = os.Hostname()
+	if err != nil {
 		return err
-	})
+	}
+	err = migrateStates(cfg.Folders)
+	if err != nil {
+		return err
+	}
+	err = initBandwidth(ctx)
+	if err != nil {
+		return err
+	}
+	syncWindows, err = parseSyncWindows(&cfg)
 	if err != nil {
 		return err
 	}
@@ -73,14 +105,32 @@ func Sync(ctx context.Context, config Config) error {
 	}
 
 	for {
+		if !waitUntilAllowed(ctx) {
+			break
+		}
+		err = startCycle()
+		if err != nil {
+			return err
+		}
+		start := time.Now()
 		err = syncOnce(ctx)
+		observeCycle(time.Since(start), err == nil)
 		if errors.Is(err, auth.ErrInvalidCredentials) {
 			return ErrInvalidCredentials
 		}
-		if err != nil {
+		if isPauseError(err) {
+			// Transfers are resumed when syncing is allowed again.
+			log.Noticeln("Sync is paused, transfers are stopped")
+			continue
+		}
+		if onlyDeletesBlocked(err) && !cfg.Once {
+			// Status is kept until deletions are confirmed.
+			syncStatus = "Blocked: " + err.Error()
+		} else if err != nil {
 			if cfg.Once {
 				return err
 			}
+			syncStatus = "Error: " + err.Error()
 			log.Error(err)
 		} else {
 			syncStatus = "Sync finished successfully"
@@ -106,50 +156,199 @@ func syncOnce(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	err = ensureRoots(ctx)
+	if !cfg.Once {
+		notifier.SetToken(token)
+		notifier.Start()
+		takeRemoteEvents()
+	}
+	var errs []error
+	for _, f := range cfg.Folders {
+		folder = f
+		err = syncFolder(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cannot sync folder %q: %w", folder.Name, err))
+		}
+		if isPauseError(err) {
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func syncFolder(ctx context.Context) error {
+	err := createPairBuckets()
 	if err != nil {
 		return err
 	}
-	tempDirPath, err = tmpdir.Create(localPath)
+	if takeRootReset(folder.Name) {
+		err = resetRoot()
+		if err != nil {
+			return err
+		}
+	}
+	err = ensureRoots(ctx)
 	if err != nil {
 		return err
 	}
-	dirCache = dircache.New(client, defaultTimeout, remoteFolderID)
-	if !cfg.Once {
-		notifier.SetToken(token)
-		notifier.Start()
-	}
-	if watcherUpdates == nil {
-		watcherUpdates, err = watcher.Watch(ctx, localPath)
+	// Nothing is written into local dir in dry-run mode.
+	if !cfg.DryRun {
+		tempDirPath, err = tmpdir.Create(localPath)
 		if err != nil {
-			log.Error(err)
+			return err
 		}
 	}
-	return syncRoots(ctx)
+	dirCache = dircache.New(client, defaultTimeout, remoteFolderID)
+	trashBin = trash.New(localPath)
+	if !localRootMissing {
+		watchLocalDir(ctx, localPath)
+	}
+	err = syncRoots(ctx)
+	if !cfg.DryRun {
+		pruneTrash()
+	}
+	return err
+}
+
+// watchLocalDir starts watching the dir for changes if it is not watched already.
+// Events from all watched dirs are forwarded to watcherUpdates channel.
+func watchLocalDir(ctx context.Context, dir string) {
+	if _, ok := watchedDirs[dir]; ok {
+		return
+	}
+	events, err := watcher.Watch(ctx, dir, cfg.Ignore)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	watchedDirs[dir] = struct{}{}
+	atomic.AddInt64(&metrics.watchedDirs, 1)
+	go func() {
+		for {
+			select {
+			case name := <-events:
+				select {
+				case watcherUpdates <- name:
+				default:
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
 }
 
 func syncRoots(ctx context.Context) error {
 	remoteURL := fmt.Sprintf("https://put.io/files/%d", remoteFolderID)
 	log.Infof("Syncing %q with %q", remoteURL, localPath)
 
+	// Walking, hashing and jobs are stopped when syncing is not allowed anymore.
+	pctx, cancel := pausableContext(ctx)
+	defer cancel()
+	jobs, _, tracked, err := planJobs(pctx)
+	if err != nil {
+		return pauseCause(pctx, err)
+	}
+
+	// Deletions are not done if there are too many of them, other jobs are still run.
+	jobs, blockedErr := guardDeletes(jobs, tracked)
+	if blockedErr != nil {
+		log.Warningln(blockedErr.Error())
+	}
+
+	// Run jobs
+	if cfg.DryRun {
+		log.Noticeln("Command run in dry-run mode, no changes will be made")
+	}
+	if len(jobs) == 0 {
+		log.Infoln("No changes detected")
+		return blockedErr
+	}
+	if cfg.DryRun {
+		for _, job := range jobs {
+			log.Infoln(job.String())
+		}
+		return blockedErr
+	}
+	syncing = true
+	defer func() { syncing = false }()
+	err = pauseCause(pctx, runJobs(pctx, jobs))
+	rememberChanges(jobs)
+	if err != nil {
+		syncStatus = "Error: " + err.Error()
+		return errors.Join(err, blockedErr)
+	}
+	return blockedErr
+}
+
+// planJobs walks local and remote folders of the current sync pair and returns the jobs for syncing them.
+// Also returns the compared files and the number of files that have a sync state.
+func planJobs(ctx context.Context) ([]iJob, map[string]*syncFile, int, error) {
 	// Read previous sync state from db.
 	states, err := readAllStates()
 	if err != nil {
-		return err
+		return nil, nil, 0, err
+	}
+
+	selected, excludeAction, err := selectedFolders(folder)
+	if err != nil {
+		return nil, nil, 0, err
 	}
+	include := walker.NewSelection(selected)
 
 	// Walk on local and remote folders in parallel
 	w := walker.Walker{
 		LocalPath:      localPath,
 		RemoteFolderID: remoteFolderID,
 		TempDirName:    tmpdir.Name,
+		TrashDirName:   trash.Name,
+		RootMarkerName: rootMarkerName,
 		Client:         client,
 		RequestTimeout: defaultTimeout,
+		Ignore:         ignore.New(localPath, cfg.Ignore),
+		Include:        include,
+		SkipLocal:      localRootMissing,
+		SkipRemote:     remoteRootMissing,
+	}
+	// Tree is put back after it is updated. It is walked again on next sync if planning fails.
+	tree := remoteTrees[folder.Name]
+	delete(remoteTrees, folder.Name)
+	includeKey := strings.Join(selected, "\n")
+	walkedAt := time.Now()
+	if dirs, ok := tree.changedDirs(remoteFolderID, includeKey); ok {
+		log.Infof("Listing %d changed remote folders", len(dirs))
+		w.RemoteDirs = dirs
+		w.KnownRemoteDir = tree.known
 	}
 	localFiles, remoteFiles, err := w.Walk(ctx)
 	if err != nil {
-		return err
+		return nil, nil, 0, err
+	}
+	ignoreSig := ignoreSignature(localFiles)
+	if w.RemoteDirs != nil && ignoreSig != tree.ignoreSig {
+		log.Infoln("Ignore rules have changed, walking the whole remote folder")
+		w.RemoteDirs, w.KnownRemoteDir = nil, nil
+		localFiles, remoteFiles, err = w.Walk(ctx)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+	}
+	observeWalk(w.LocalDuration, w.RemoteDuration)
+	if w.RemoteDirs != nil {
+		tree.update(w.RemoteDirs, remoteFiles, w.RemoteIgnored)
+		tree.events, tree.changed, tree.dirs = nil, nil, nil
+	} else {
+		tree = &remoteTree{
+			rootID:    remoteFolderID,
+			include:   includeKey,
+			ignoreSig: ignoreSig,
+			files:     remoteFiles,
+			ignored:   w.RemoteIgnored,
+			walkedAt:  walkedAt,
+			complete:  true,
+		}
 	}
+	remoteFiles = tree.files
+	ignored := append(append([]string{}, w.LocalIgnored...), tree.ignored...)
 
 	// Set DirCache entries for existing remote folders
 	for _, rf := range remoteFiles {
@@ -160,38 +359,42 @@ func syncRoots(ctx context.Context) error {
 
 	// Calculate what needs to be done
 	syncFiles := groupFiles(states, localFiles, remoteFiles)
+	filterOutIgnored(syncFiles, ignored, w.Ignore)
 	filterOutInvalidNames(syncFiles)
-	jobs := reconciliation(syncFiles)
+	jobs := filterOutExcluded(syncFiles, include, excludeAction, syncMode(folder.Mode))
+	err = hashLocalFiles(ctx, syncFiles)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	resolutions, err := readConflictResolutions()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	opts := reconOptions{
+		mode:           syncMode(folder.Mode),
+		conflictPolicy: conflictPolicy(folder.ConflictPolicy),
+		hostname:       hostname,
+		now:            time.Now(),
+		resolutions:    resolutions,
+	}
+	jobs = append(jobs, reconciliation(syncFiles, opts)...)
+	err = saveConflicts(syncFiles, opts.now)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	// Files that failed recently are retried later, so they do not fail every sync.
+	jobs, err = filterOutFailedJobs(jobs, syncFiles)
+	if err != nil {
+		return nil, nil, 0, err
+	}
 
 	// Print jobs for debugging
 	for _, job := range jobs {
 		log.Debugln("Job:", job.String())
 	}
 	// dirCache.Debug()
-
-	// Run all jobs one by one
-	if cfg.DryRun {
-		log.Noticeln("Command run in dry-run mode, no changes will be made")
-	}
-	if len(jobs) == 0 {
-		log.Infoln("No changes detected")
-		return nil
-	}
-	syncing = true
-	defer func() { syncing = false }()
-	for _, job := range jobs {
-		syncStatus = job.String()
-		log.Infoln(syncStatus)
-		if cfg.DryRun {
-			continue
-		}
-		err = job.Run(ctx)
-		if err != nil {
-			syncStatus = "Error: " + err.Error()
-			return err
-		}
-	}
-	return nil
+	remoteTrees[folder.Name] = tree
+	return jobs, syncFiles, len(states), nil
 }
 
 func waitNextSync(ctx context.Context) bool {
@@ -210,6 +413,10 @@ func waitNextSync(ctx context.Context) bool {
 	} else {
 		d = 15 * time.Minute
 	}
+	// Failed files are retried without waiting for changes.
+	if t, ok := nextRetry(); ok && time.Until(t) < d {
+		d = time.Until(t)
+	}
 	for {
 		select {
 		case <-time.After(d):
@@ -3,10 +3,12 @@ package putiosync
 import (
 	"fmt"
 	"os"
+	"path"
 	"strings"
 
 	"github.com/cenkalti/log"
 	"github.com/putdotio/go-putio"
+	"github.com/putdotio/putio-sync/v2/internal/ignore"
 	"github.com/putdotio/putio-sync/v2/internal/walker"
 	"golang.org/x/text/unicode/norm"
 )
@@ -29,6 +31,14 @@ type syncFile struct {
 	state   *stateType
 	relpath string
 	skip    bool
+	// CRC32 checksum of the local file, set only if it is needed for detecting changes.
+	localCRC32 string
+	// Other remote files with the same path.
+	duplicates []iRemoteFile
+	// Set by reconciliation if the file is conflicting.
+	conflict conflictKind
+	// Set by reconciliation if there are jobs for resolving the conflict.
+	conflictResolved bool
 }
 
 func (f *syncFile) String() string {
@@ -72,6 +82,9 @@ func groupFiles(states []stateType, localFiles []*walker.LocalFile, remoteFiles
 	}
 	for _, rf := range remoteFiles {
 		sf := initSyncFile(rf.RelPath())
+		if sf.remote != nil {
+			sf.duplicates = append(sf.duplicates, sf.remote)
+		}
 		sf.remote = rf
 	}
 	for _, state := range states {
@@ -97,3 +110,30 @@ func filterOutInvalidNames(syncFiles map[string]*syncFile) {
 		delete(syncFiles, name)
 	}
 }
+
+// filterOutIgnored removes the files matching ignore rules from syncFiles.
+// A file is removed if it is ignored on any side, or it is inside an ignored folder.
+// Their states are not touched, so ignored files are never deleted on the other side.
+func filterOutIgnored(syncFiles map[string]*syncFile, ignored []string, m *ignore.Matcher) {
+	ignoredPaths := make(map[string]struct{}, len(ignored))
+	for _, relpath := range ignored {
+		ignoredPaths[norm.NFC.String(relpath)] = struct{}{}
+	}
+	isIgnored := func(sf *syncFile) bool {
+		if sf.state != nil && m.Match(sf.relpath, sf.state.IsDir) {
+			return true
+		}
+		for p := sf.relpath; p != "."; p = path.Dir(p) {
+			if _, ok := ignoredPaths[p]; ok {
+				return true
+			}
+		}
+		return false
+	}
+	for relpath, sf := range syncFiles {
+		if isIgnored(sf) {
+			log.Debugf("Ignoring file: %q", relpath)
+			delete(syncFiles, relpath)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package putiosync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/log"
+	"github.com/putdotio/putio-sync/v2/internal/schedule"
+)
+
+// errOutsideSyncWindow is the cause of cancellation when the sync window closes while syncing.
+var errOutsideSyncWindow = errors.New("outside of sync window")
+
+// errPaused is the cause of cancellation when sync is paused by the user.
+var errPaused = errors.New("paused by user")
+
+// pauseState is the pause set by the user from the control API.
+// Changed channel is closed when the state changes, so waiters do not have to poll.
+var pauseState = struct {
+	m       sync.Mutex
+	paused  bool
+	changed chan struct{}
+}{changed: make(chan struct{})}
+
+func setPaused(paused bool) {
+	pauseState.m.Lock()
+	defer pauseState.m.Unlock()
+	if pauseState.paused == paused {
+		return
+	}
+	pauseState.paused = paused
+	close(pauseState.changed)
+	pauseState.changed = make(chan struct{})
+	if paused {
+		log.Noticeln("Sync is paused by user")
+	} else {
+		log.Noticeln("Sync is resumed by user")
+	}
+}
+
+// isPaused returns the pause state and a channel that is closed when it changes.
+func isPaused() (bool, <-chan struct{}) {
+	pauseState.m.Lock()
+	defer pauseState.m.Unlock()
+	return pauseState.paused, pauseState.changed
+}
+
+// isPauseError reports whether err is returned because syncing is not allowed anymore.
+func isPauseError(err error) bool {
+	return errors.Is(err, errOutsideSyncWindow) || errors.Is(err, errPaused)
+}
+
+// How often sync windows are checked.
+const syncWindowInterval = 30 * time.Second
+
+// syncWindows is the parsed value of SyncWindows in config.
+var syncWindows []schedule.Window
+
+func parseSyncWindows(c *Config) ([]schedule.Window, error) {
+	l := make([]schedule.Window, 0, len(c.SyncWindows))
+	for _, s := range c.SyncWindows {
+		w, err := schedule.Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, w)
+	}
+	return l, nil
+}
+
+// syncBlocked returns the reason why syncing is not allowed at time t.
+// Returns nil if syncing is allowed.
+func syncBlocked(t time.Time) error {
+	if paused, _ := isPaused(); paused {
+		return errPaused
+	}
+	if len(syncWindows) == 0 {
+		return nil
+	}
+	for _, w := range syncWindows {
+		if w.Contains(t) {
+			return nil
+		}
+	}
+	return errOutsideSyncWindow
+}
+
+// pausableContext returns a context that is cancelled when syncing is not allowed anymore.
+// The reason can be retrieved with pauseCause.
+func pausableContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	go func() {
+		ticker := time.NewTicker(syncWindowInterval)
+		defer ticker.Stop()
+		for {
+			_, changed := isPaused()
+			select {
+			case <-ticker.C:
+			case <-changed:
+			case <-ctx.Done():
+				return
+			}
+			if err := syncBlocked(time.Now()); err != nil {
+				cancel(err)
+				return
+			}
+		}
+	}()
+	return ctx, func() { cancel(nil) }
+}
+
+// waitUntilAllowed blocks until syncing is allowed.
+// Changes detected while waiting are counted and synced when syncing is allowed again.
+// Returns false if ctx is cancelled.
+func waitUntilAllowed(ctx context.Context) bool {
+	err := syncBlocked(time.Now())
+	if err == nil {
+		return true
+	}
+	log.Noticef("Sync is paused: %s", err.Error())
+	ticker := time.NewTicker(syncWindowInterval)
+	defer ticker.Stop()
+	var queued int
+	for {
+		status := "Waiting for sync window"
+		if errors.Is(err, errPaused) {
+			status = "Paused"
+		}
+		if queued == 0 {
+			syncStatus = status
+		} else {
+			syncStatus = fmt.Sprintf("%s (%d changes queued)", status, queued)
+		}
+		_, changed := isPaused()
+		select {
+		case <-ticker.C:
+		case <-changed:
+		case name := <-notifier.HasUpdates:
+			log.Debugf("Change detected at remote filesystem: %q", name)
+			queued++
+		case name := <-watcherUpdates:
+			log.Debugf("Change detected at local filesystem: %q", name)
+			queued++
+		case <-triggerSyncC:
+			log.Debugf("Sync triggered manually")
+			queued++
+		case <-ctx.Done():
+			return false
+		}
+		err = syncBlocked(time.Now())
+		if err == nil {
+			log.Noticeln("Resuming sync")
+			return true
+		}
+	}
+}
+
+// pauseCause returns the reason if ctx is cancelled by pausableContext, otherwise returns err.
+func pauseCause(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if cause := context.Cause(ctx); cause != nil && !errors.Is(cause, context.Canceled) {
+		return cause
+	}
+	return err
+}
@@ -0,0 +1,23 @@
+package putiosync
+
+import (
+	"time"
+
+	"github.com/cenkalti/log"
+	"github.com/putdotio/putio-sync/v2/internal/size"
+	"github.com/putdotio/putio-sync/v2/internal/trash"
+)
+
+const defaultTrashMaxAge = 30 * 24 * time.Hour
+
+// trashBin keeps local files deleted by sync in the sync pair that is being synced.
+var trashBin *trash.Trash
+
+// pruneTrash permanently deletes the items in trash exceeding the limits in config.
+func pruneTrash() {
+	maxSize, _ := size.Parse(cfg.TrashMaxSize)
+	err := trashBin.Prune(cfg.TrashMaxAge, maxSize, time.Now())
+	if err != nil {
+		log.Errorln("cannot prune trash:", err.Error())
+	}
+}
@@ -0,0 +1,216 @@
+"use strict";
+
+// Token is asked when the server requires a bearer token. Basic auth is handled by the browser.
+const tokenKey = "putio-sync-token";
+const maxErrors = 50;
+
+function headers() {
+  const h = { "Content-Type": "application/json" };
+  const token = localStorage.getItem(tokenKey);
+  if (token) {
+    h["Authorization"] = "Bearer " + token;
+  }
+  return h;
+}
+
+async function api(method, path, body) {
+  const resp = await fetch(path, { method, headers: headers(), body: body && JSON.stringify(body) });
+  if (resp.status === 401 && !resp.headers.get("WWW-Authenticate")) {
+    const token = prompt("Server token");
+    if (token) {
+      localStorage.setItem(tokenKey, token);
+      return api(method, path, body);
+    }
+  }
+  if (!resp.ok) {
+    throw new Error(resp.status + " " + (await resp.text()));
+  }
+  return resp;
+}
+
+function cell(row, text, className) {
+  const td = document.createElement("td");
+  td.textContent = text;
+  if (className) {
+    td.className = className;
+  }
+  row.appendChild(td);
+  return td;
+}
+
+function formatBytes(n) {
+  const units = ["B", "KB", "MB", "GB", "TB"];
+  let i = 0;
+  while (n >= 1024 && i < units.length - 1) {
+    n /= 1024;
+    i++;
+  }
+  return n.toFixed(i ? 1 : 0) + " " + units[i];
+}
+
+function jobPath(job) {
+  return job.to ? job.path + " → " + job.to : job.path;
+}
+
+function renderStatus(s) {
+  let text = s.status;
+  if (s.paused) {
+    text += " (paused)";
+  }
+  document.getElementById("status").textContent = text;
+  const queued = s.queued || { jobs: 0, bytes: 0 };
+  document.getElementById("queued").textContent = queued.jobs ? queued.jobs + " jobs queued, " + formatBytes(queued.bytes) + " to transfer" : "";
+  const tbody = document.getElementById("quarantined");
+  tbody.replaceChildren();
+  for (const q of s.quarantined || []) {
+    const row = document.createElement("tr");
+    cell(row, q.folder);
+    cell(row, q.path);
+    cell(row, String(q.attempts));
+    cell(row, q.lastError, "error");
+    const button = document.createElement("button");
+    button.textContent = "Retry";
+    button.onclick = () => run(() => api("POST", "/retry", { folder: q.folder, path: q.path }));
+    cell(row, "").appendChild(button);
+    tbody.appendChild(row);
+  }
+}
+
+function renderJobs(jobs) {
+  const active = document.getElementById("active");
+  const finished = document.getElementById("finished");
+  active.replaceChildren();
+  finished.replaceChildren();
+  for (const job of jobs) {
+    const row = document.createElement("tr");
+    if (job.state === "queued" || job.state === "running") {
+      cell(row, job.type);
+      cell(row, jobPath(job));
+      cell(row, job.state);
+      const td = cell(row, "");
+      if (job.bytesTotal > 0) {
+        const bar = document.createElement("progress");
+        bar.max = job.bytesTotal;
+        bar.value = job.bytesDone;
+        td.appendChild(bar);
+        let text = " " + formatBytes(job.bytesDone) + " / " + formatBytes(job.bytesTotal);
+        if (job.state === "running") {
+          text += ", " + formatBytes(job.speed) + "/s, " + job.eta + "s left";
+        }
+        td.appendChild(document.createTextNode(text));
+      }
+      cell(row, job.attempt > 1 ? job.attempt + " (last error: " + job.lastError + ")" : String(job.attempt));
+      active.appendChild(row);
+    } else {
+      cell(row, new Date(job.finishedAt).toLocaleString());
+      cell(row, job.type);
+      cell(row, jobPath(job));
+      cell(row, job.state, job.state);
+      cell(row, job.error || "", "error");
+      finished.appendChild(row);
+    }
+  }
+}
+
+function addLog(entry) {
+  const level = entry.level.toLowerCase();
+  if (level !== "error" && level !== "warning" && level !== "critical") {
+    return;
+  }
+  const list = document.getElementById("errors");
+  const li = document.createElement("li");
+  li.className = level;
+  li.textContent = new Date(entry.time).toLocaleString() + " " + entry.message;
+  list.prepend(li);
+  while (list.children.length > maxErrors) {
+    list.lastChild.remove();
+  }
+}
+
+async function loadConflicts() {
+  const resp = await api("GET", "/conflicts");
+  const conflicts = await resp.json();
+  const tbody = document.getElementById("conflicts");
+  tbody.replaceChildren();
+  for (const c of conflicts) {
+    const row = document.createElement("tr");
+    cell(row, c.folder);
+    cell(row, c.path);
+    cell(row, c.kind);
+    cell(row, c.resolution || "");
+    const td = cell(row, "");
+    const actions = c.kind === "name-collision" ? ["keep-both"] : ["keep-local", "keep-remote", "keep-both"];
+    for (const action of actions) {
+      const button = document.createElement("button");
+      button.textContent = action;
+      button.onclick = () => run(async () => {
+        await api("POST", "/conflicts/resolve", { folder: c.folder, path: c.path, action });
+        await loadConflicts();
+      });
+      td.appendChild(button);
+    }
+    tbody.appendChild(row);
+  }
+}
+
+// Events are read with fetch instead of EventSource, so the token header can be sent.
+async function connect() {
+  const resp = await api("GET", "/events");
+  const reader = resp.body.pipeThrough(new TextDecoderStream()).getReader();
+  let buf = "";
+  for (;;) {
+    const { value, done } = await reader.read();
+    if (done) {
+      throw new Error("events stream is closed");
+    }
+    buf += value;
+    let i;
+    while ((i = buf.indexOf("\n\n")) >= 0) {
+      const message = buf.slice(0, i);
+      buf = buf.slice(i + 2);
+      let event = "";
+      let data = "";
+      for (const line of message.split("\n")) {
+        if (line.startsWith("event: ")) {
+          event = line.slice(7);
+        } else if (line.startsWith("data: ")) {
+          data += line.slice(6);
+        }
+      }
+      const v = JSON.parse(data);
+      if (event === "status") {
+        renderStatus(v);
+      } else if (event === "jobs") {
+        renderJobs(v);
+      } else if (event === "log") {
+        addLog(v);
+      }
+    }
+  }
+}
+
+async function run(fn) {
+  try {
+    await fn();
+  } catch (e) {
+    alert(e.message);
+  }
+}
+
+async function keepConnected() {
+  for (;;) {
+    try {
+      await connect();
+    } catch (e) {
+      document.getElementById("status").textContent = "Disconnected: " + e.message;
+    }
+    await new Promise((resolve) => setTimeout(resolve, 2000));
+  }
+}
+
+for (const name of ["trigger", "pause", "resume"]) {
+  document.getElementById(name).onclick = () => run(() => api("POST", "/" + name));
+}
+loadConflicts().catch(() => {});
+setInterval(() => loadConflicts().catch(() => {}), 10000);
+keepConnected();